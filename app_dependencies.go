@@ -0,0 +1,99 @@
+package pipenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/draft"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// contributeAppDependencies installs the dependencies locked in the app's
+// Pipfile.lock into a virtualenv in the packages layer. The layer is reused
+// as long as the checksum of Pipfile.lock and the version of python are
+// unchanged, since the virtualenv links to the interpreter and keeps its
+// packages under lib/pythonX.Y.
+func contributeAppDependencies(
+	context packit.BuildContext,
+	pipenvLayerPath string,
+	cacheLayerPath string,
+	appInstallProcess AppDependencyInstallProcess,
+	siteProcess SitePackageProcess,
+	logger scribe.Emitter,
+	clock chronos.Clock,
+) (packit.Layer, error) {
+	lockPath := filepath.Join(context.WorkingDir, "Pipfile.lock")
+	if _, err := os.Stat(lockPath); err != nil {
+		return packit.Layer{}, fmt.Errorf("installing application dependencies requires a Pipfile.lock: %w", err)
+	}
+
+	lockChecksum, err := fs.NewChecksumCalculator().Sum(lockPath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	pythonVersion, err := siteProcess.PythonVersion()
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	// The layer is always available at launch; downstream buildpacks that
+	// require site-packages decide whether it is also needed at build time.
+	_, build := draft.NewPlanner().MergeLayerTypes(SitePackages, context.Plan.Entries)
+
+	packagesLayer, err := context.Layers.Get(Packages)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	cachedChecksum, _ := packagesLayer.Metadata[LockfileChecksumKey].(string)
+	cachedPythonVersion, _ := packagesLayer.Metadata[PythonVersionKey].(string)
+	if cachedChecksum == lockChecksum && cachedPythonVersion == pythonVersion {
+		logger.Process("Reusing cached layer %s", packagesLayer.Path)
+		packagesLayer.Launch, packagesLayer.Build, packagesLayer.Cache = true, build, build
+
+		return packagesLayer, nil
+	}
+
+	packagesLayer, err = packagesLayer.Reset()
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	packagesLayer.Launch, packagesLayer.Build, packagesLayer.Cache = true, build, build
+
+	sitePackagesPath, err := siteProcess.Execute(pipenvLayerPath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Process("Installing application dependencies")
+	logger.Subprocess("Running 'pipenv install --deploy'")
+
+	duration, err := clock.Measure(func() error {
+		return appInstallProcess.Execute(context.WorkingDir, pipenvLayerPath, strings.TrimRight(sitePackagesPath, "\n"), packagesLayer.Path, cacheLayerPath)
+	})
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	packagesLayer.Metadata = map[string]interface{}{
+		LockfileChecksumKey: lockChecksum,
+		PythonVersionKey:    pythonVersion,
+	}
+
+	packagesLayer.SharedEnv.Override("VIRTUAL_ENV", packagesLayer.Path)
+
+	logger.EnvironmentVariables(packagesLayer)
+
+	return packagesLayer, nil
+}
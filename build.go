@@ -1,7 +1,9 @@
 package pipenv
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -18,6 +20,8 @@ import (
 //go:generate faux --interface InstallProcess --output fakes/install_process.go
 //go:generate faux --interface SitePackageProcess --output fakes/site_package_process.go
 //go:generate faux --interface SBOMGenerator --output fakes/sbom_generator.go
+//go:generate faux --interface AppDependencyInstallProcess --output fakes/app_dependency_install_process.go
+//go:generate faux --interface RequirementsExportProcess --output fakes/requirements_export_process.go
 
 // DependencyManager defines the interface for picking the best matching
 // dependency and installing it.
@@ -28,28 +32,46 @@ type DependencyManager interface {
 
 // InstallProcess defines the interface for installing the pipenv dependency into a layer.
 type InstallProcess interface {
-	Execute(version, destLayerPath string) error
+	Execute(version, destLayerPath, cachePath string, breakSystemPackages bool) error
 }
 
-// SitePackageProcess defines the interface for looking up site packages within a layer.
+// SitePackageProcess defines the interface for looking up site packages within a layer
+// and the version of the python interpreter they are for.
 type SitePackageProcess interface {
 	Execute(targetLayerPath string) (string, error)
+	PythonVersion() (string, error)
 }
 
 type SBOMGenerator interface {
 	GenerateFromDependency(dependency postal.Dependency, dir string) (sbom.SBOM, error)
 }
 
+// AppDependencyInstallProcess defines the interface for installing the
+// application dependencies from Pipfile.lock into a layer.
+type AppDependencyInstallProcess interface {
+	Execute(workingDir, pipenvLayerPath, sitePackagesPath, targetLayerPath, cachePath string) error
+}
+
+// RequirementsExportProcess defines the interface for exporting Pipfile.lock
+// as a requirements file.
+type RequirementsExportProcess interface {
+	Execute(workingDir, pipenvLayerPath, sitePackagesPath, outputPath string, dev bool, categories []string) error
+}
+
 // Build will return a packit.BuildFunc that will be invoked during the build
 // phase of the buildpack lifecycle.
 //
 // Build will find the right pipenv dependency to install, install it in a
 // layer, and generate Bill-of-Materials. It also makes use of the checksum of
-// the dependency to reuse the layer when possible.
+// the dependency to reuse the layer when possible. The optional steps around
+// the installation, and the settings that control them (see Configuration),
+// are described in the README.
 func Build(
 	dependencyManager DependencyManager,
 	installProcess InstallProcess,
 	siteProcess SitePackageProcess,
+	appInstallProcess AppDependencyInstallProcess,
+	requirementsProcess RequirementsExportProcess,
 	sbomGenerator SBOMGenerator,
 	logger scribe.Emitter,
 	clock chronos.Clock,
@@ -57,6 +79,32 @@ func Build(
 	return func(context packit.BuildContext) (packit.BuildResult, error) {
 		logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
 
+		config, err := LoadConfiguration(context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		config.Log(logger)
+
+		err = ValidatePipfile(context.WorkingDir, config.Validation, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = CheckPipfileLock(context.WorkingDir, config.LockCheck, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		vulnerabilityScanner, err := NewVulnerabilityScanner(context.Platform.Path, config)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		licensePolicy, err := NewLicensePolicy(config)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
 		planner := draft.NewPlanner()
 
 		logger.Process("Resolving Pipenv version")
@@ -65,20 +113,45 @@ func Build(
 		logger.Candidates(sortedEntries)
 
 		version, _ := entry.Metadata["version"].(string)
+		buildpackTOMLPath := filepath.Join(context.CNBPath, "buildpack.toml")
+
+		version, skippedVersions, err := resolvePythonCompatibleVersion(buildpackTOMLPath, entry.Name, version, context.Stack, config.AllowPrerelease, siteProcess, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		resolver := dependencyManager
+		if selector, ok := dependencyManager.(PrereleaseSelector); ok {
+			resolver = selector.WithPrerelease(config.AllowPrerelease)
+		}
 
-		dependency, err := dependencyManager.Resolve(filepath.Join(context.CNBPath, "buildpack.toml"), entry.Name, version, context.Stack)
+		dependency, err := resolver.Resolve(buildpackTOMLPath, entry.Name, version, context.Stack)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
 		logger.SelectedDependency(entry, dependency, clock.Now())
 
+		versionSource, _ := entry.Metadata["version-source"].(string)
+		report := NewBuildReport(dependency, versionSource)
+		report.SkippedVersions = skippedVersions
+
 		legacySBOM := dependencyManager.GenerateBillOfMaterials(dependency)
 		launch, build := planner.MergeLayerTypes(Pipenv, context.Plan.Entries)
 
 		var launchMetadata packit.LaunchMetadata
 		if launch {
 			launchMetadata.BOM = legacySBOM
+
+			launchMetadata.Labels, err = imageLabels(dependency, config)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			launchMetadata.Processes, err = ScriptProcesses(context.WorkingDir, config, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
 		}
 
 		var buildMetadata packit.BuildMetadata
@@ -86,85 +159,217 @@ func Build(
 			buildMetadata.BOM = legacySBOM
 		}
 
+		cacheLayer, err := contributeDownloadCache(context, config, logger, clock)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
 		pipenvLayer, err := context.Layers.Get(Pipenv)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
+		// pipenv runs from the layer to install the app's dependencies or to
+		// export its requirements, so it is then needed at build time whether
+		// or not anything requires it.
+		layerBuild := build || config.InstallAppDependencies || config.ExportRequirements
+
+		// Only the contents of cached layers are restored. The build report is
+		// written into every layer this buildpack installs, so a layer without
+		// one is installed again even when the checksum matches.
+		_, err = os.Stat(filepath.Join(pipenvLayer.Path, BuildReportFile))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return packit.BuildResult{}, fmt.Errorf("failed to check the cached layer: %w", err)
+		}
+		restored := err == nil
+
 		cachedChecksum, ok := pipenvLayer.Metadata[DependencyChecksumKey].(string)
-		if ok && cachedChecksum == dependency.Checksum {
+		if ok && cachedChecksum == dependency.Checksum && restored {
 			logger.Process("Reusing cached layer %s", pipenvLayer.Path)
-			pipenvLayer.Launch, pipenvLayer.Build, pipenvLayer.Cache = launch, build, build
-
-			return packit.BuildResult{
-				Layers: []packit.Layer{pipenvLayer},
-				Build:  buildMetadata,
-				Launch: launchMetadata,
-			}, nil
+			pipenvLayer.Launch, pipenvLayer.Build, pipenvLayer.Cache = launch, layerBuild, true
+
+			report.Layer = BuildReportLayer{
+				Name:        pipenvLayer.Name,
+				Path:        pipenvLayer.Path,
+				Reused:      true,
+				ReuseReason: "cached dependency checksum matches the selected dependency",
+			}
+
+			// The packages are listed again, rather than read from the
+			// previous report, so that the scans below see what is installed.
+			report.SitePackages, report.Packages, err = listSitePackages(siteProcess, pipenvLayer.Path)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		} else {
+			report.Layer = BuildReportLayer{
+				Name:        pipenvLayer.Name,
+				Path:        pipenvLayer.Path,
+				ReuseReason: "no cached layer",
+			}
+			switch {
+			case ok && cachedChecksum != dependency.Checksum:
+				report.Layer.ReuseReason = fmt.Sprintf("cached dependency checksum %s does not match the selected dependency", cachedChecksum)
+			case ok:
+				report.Layer.ReuseReason = "cached layer contents were not restored"
+			}
+
+			pipenvLayer, err = pipenvLayer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			// The layer is cached even when pipenv is only needed at launch, so
+			// that its contents are there to reuse in the next build.
+			pipenvLayer.Launch, pipenvLayer.Build, pipenvLayer.Cache = launch, layerBuild, true
+
+			logger.Process("Executing build process")
+			logger.Subprocess(fmt.Sprintf("Installing Pipenv %s", dependency.Version))
+
+			duration, err := clock.Measure(func() error {
+				return installProcess.Execute(dependency.Version, pipenvLayer.Path, cacheLayer.Path, config.BreakSystemPackages)
+			})
+
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			// pip writes the absolute path of the interpreter into the console
+			// scripts, which breaks a reused layer once that path changes.
+			binPath := filepath.Join(pipenvLayer.Path, "bin")
+			relocated, err := RelocateConsoleScripts(binPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			if len(relocated) > 0 {
+				logger.Action("Rewrote %s to use %q", strings.Join(relocated, ", "), RelocatableShebang)
+			}
+
+			err = CheckConsoleScripts(binPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			logger.Action("Completed in %s", duration.Round(time.Millisecond))
+			logger.Break()
+
+			report.Durations.InstallMilliseconds = duration.Milliseconds()
+
+			logger.GeneratingSBOM(pipenvLayer.Path)
+			var sbomContent sbom.SBOM
+			duration, err = clock.Measure(func() error {
+				sbomContent, err = sbomGenerator.GenerateFromDependency(dependency, pipenvLayer.Path)
+				return err
+			})
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			logger.Action("Completed in %s", duration.Round(time.Millisecond))
+			logger.Break()
+
+			report.Durations.SBOMMilliseconds = duration.Milliseconds()
+
+			logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
+			pipenvLayer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			pipenvLayer.Metadata = map[string]interface{}{
+				DependencyChecksumKey: dependency.Checksum,
+			}
+
+			// Look up the site packages path and prepend it onto $PYTHONPATH
+			report.SitePackages, report.Packages, err = listSitePackages(siteProcess, pipenvLayer.Path)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			pipenvLayer.SharedEnv.Prepend("PYTHONPATH", report.SitePackages, ":")
+
+			logger.EnvironmentVariables(pipenvLayer)
 		}
 
-		pipenvLayer, err = pipenvLayer.Reset()
+		report.Vulnerabilities, err = vulnerabilityScanner.Scan(context.WorkingDir, report.Packages, logger)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		pipenvLayer.Launch, pipenvLayer.Build, pipenvLayer.Cache = launch, build, build
-
-		logger.Process("Executing build process")
-		logger.Subprocess(fmt.Sprintf("Installing Pipenv %s", dependency.Version))
-
-		duration, err := clock.Measure(func() error {
-			return installProcess.Execute(dependency.Version, pipenvLayer.Path)
-		})
-
+		err = WriteBuildReport(report, pipenvLayer.Path, config.ReportPath)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		logger.Action("Completed in %s", duration.Round(time.Millisecond))
-		logger.Break()
-
-		logger.GeneratingSBOM(pipenvLayer.Path)
-		var sbomContent sbom.SBOM
-		duration, err = clock.Measure(func() error {
-			sbomContent, err = sbomGenerator.GenerateFromDependency(dependency, pipenvLayer.Path)
-			return err
-		})
+		// The report is written first so that it records the findings that
+		// fail the build.
+		err = vulnerabilityScanner.Check(report.Vulnerabilities)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		logger.Action("Completed in %s", duration.Round(time.Millisecond))
-		logger.Break()
-
-		logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
-		pipenvLayer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+		err = licensePolicy.Check(dependency, report.Packages, logger)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		pipenvLayer.Metadata = map[string]interface{}{
-			DependencyChecksumKey: dependency.Checksum,
+		// The site packages baked into $PYTHONPATH are only valid for the
+		// python pipenv was installed with, so they are recomputed at launch.
+		// packit copies the helper into the layer, which is only safe because
+		// a reused layer always has its contents restored.
+		if launch {
+			pipenvLayer.ExecD = []string{filepath.Join(context.CNBPath, "bin", PythonPathHelper)}
 		}
 
-		// Look up the site packages path and prepend it onto $PYTHONPATH
-		sitePackagesPath, err := siteProcess.Execute(pipenvLayer.Path)
-		if err != nil {
-			return packit.BuildResult{}, err
+		layers := []packit.Layer{pipenvLayer}
+		if config.InstallAppDependencies {
+			packagesLayer, err := contributeAppDependencies(context, pipenvLayer.Path, cacheLayer.Path, appInstallProcess, siteProcess, logger, clock)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			layers = append(layers, packagesLayer)
 		}
-
-		if sitePackagesPath == "" {
-			return packit.BuildResult{}, fmt.Errorf("pipenv installation failed: site packages are missing from the pipenv layer")
+		if config.ExportRequirements {
+			requirementsLayer, err := contributeRequirements(context, pipenvLayer.Path, config, requirementsProcess, siteProcess, logger, clock)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			layers = append(layers, requirementsLayer)
 		}
+		layers = append(layers, cacheLayer)
 
-		pipenvLayer.SharedEnv.Prepend("PYTHONPATH", strings.TrimRight(sitePackagesPath, "\n"), ":")
-
-		logger.EnvironmentVariables(pipenvLayer)
+		if len(launchMetadata.Processes) > 0 {
+			logger.LaunchProcesses(launchMetadata.Processes)
+		}
 
 		return packit.BuildResult{
-			Layers: []packit.Layer{pipenvLayer},
+			Layers: layers,
 			Build:  buildMetadata,
 			Launch: launchMetadata,
 		}, nil
 	}
 }
+
+// listSitePackages returns the site packages path of the pipenv layer and the
+// packages installed there.
+func listSitePackages(siteProcess SitePackageProcess, layerPath string) (string, []InstalledPackage, error) {
+	sitePackagesPath, err := siteProcess.Execute(layerPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if sitePackagesPath == "" {
+		return "", nil, fmt.Errorf("pipenv installation failed: site packages are missing from the pipenv layer")
+	}
+
+	sitePackagesPath = strings.TrimRight(sitePackagesPath, "\n")
+	packages, err := ListInstalledPackages(sitePackagesPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if packages == nil {
+		packages = []InstalledPackage{}
+	}
+
+	return sitePackagesPath, packages, nil
+}
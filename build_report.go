@@ -0,0 +1,132 @@
+package pipenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+// BuildReportSchemaVersion is the version of the build report format. It
+// must be incremented whenever a field is removed or changes meaning.
+const BuildReportSchemaVersion = 1
+
+// BuildReport is a machine-readable summary of what the build phase did.
+type BuildReport struct {
+	SchemaVersion int `json:"schema_version"`
+
+	Dependency    BuildReportDependency `json:"dependency"`
+	VersionSource string                `json:"version_source,omitempty"`
+
+	SkippedVersions []SkippedVersion `json:"skipped_versions,omitempty"`
+
+	Layer BuildReportLayer `json:"layer"`
+
+	SitePackages string             `json:"site_packages"`
+	Packages     []InstalledPackage `json:"packages"`
+
+	Vulnerabilities []VulnerabilityFinding `json:"vulnerabilities,omitempty"`
+
+	Durations BuildReportDurations `json:"durations"`
+}
+
+// BuildReportDependency describes the resolved pipenv dependency.
+type BuildReportDependency struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Checksum string `json:"checksum"`
+	Source   string `json:"source,omitempty"`
+	PURL     string `json:"purl,omitempty"`
+}
+
+// BuildReportLayer describes the pipenv layer and whether it was reused from
+// a previous build.
+type BuildReportLayer struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Reused      bool   `json:"reused"`
+	ReuseReason string `json:"reuse_reason"`
+}
+
+// BuildReportDurations holds the time taken by each step of the build, in
+// milliseconds. Steps that were skipped are zero.
+type BuildReportDurations struct {
+	InstallMilliseconds int64 `json:"install_ms"`
+	SBOMMilliseconds    int64 `json:"sbom_ms"`
+}
+
+// NewBuildReport creates a BuildReport for the given dependency.
+func NewBuildReport(dependency postal.Dependency, versionSource string) BuildReport {
+	return BuildReport{
+		SchemaVersion: BuildReportSchemaVersion,
+		Dependency: BuildReportDependency{
+			ID:       dependency.ID,
+			Name:     dependency.Name,
+			Version:  dependency.Version,
+			Checksum: dependency.Checksum,
+			Source:   dependency.Source,
+			PURL:     dependency.PURL,
+		},
+		VersionSource: versionSource,
+		Packages:      []InstalledPackage{},
+	}
+}
+
+// ReadBuildReport reads a report previously written with Write. A missing
+// file results in an empty report and no error.
+func ReadBuildReport(path string) (BuildReport, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BuildReport{}, nil
+		}
+		return BuildReport{}, fmt.Errorf("failed to read build report: %w", err)
+	}
+
+	var report BuildReport
+	err = json.Unmarshal(content, &report)
+	if err != nil {
+		return BuildReport{}, fmt.Errorf("failed to parse build report: %w", err)
+	}
+
+	return report, nil
+}
+
+// WriteBuildReport writes the report into the given layer and, when reportPath
+// ($BP_PIPENV_REPORT_PATH) is set, to that path as well.
+func WriteBuildReport(report BuildReport, layerPath, reportPath string) error {
+	err := report.Write(filepath.Join(layerPath, BuildReportFile))
+	if err != nil {
+		return err
+	}
+
+	if reportPath != "" {
+		return report.Write(reportPath)
+	}
+
+	return nil
+}
+
+// Write writes the report as JSON to the given path, creating any missing
+// parent directories.
+func (r BuildReport) Write(path string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode build report: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write build report: %w", err)
+	}
+
+	err = os.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write build report: %w", err)
+	}
+
+	return nil
+}
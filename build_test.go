@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
@@ -33,6 +34,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		dependencyManager *fakes.DependencyManager
 		installProcess    *fakes.InstallProcess
 		siteProcess       *fakes.SitePackageProcess
+		appInstallProcess *fakes.AppDependencyInstallProcess
+		requirements      *fakes.RequirementsExportProcess
 		sbomGenerator     *fakes.SBOMGenerator
 
 		buffer *bytes.Buffer
@@ -52,6 +55,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			ID:       "pipenv",
 			Name:     "pipenv-dependency-name",
 			Checksum: "pipenv-dependency-sha",
+			CPE:      "cpe:2.3:a:pypa:pipenv:pipenv-dependency-version:*:*:*:*:python:*:*",
+			PURL:     "pkg:pypi/pipenv@pipenv-dependency-version?checksum=pipenv-dependency-sha&file_name=pipenv-dependency-uri",
 			Stacks:   []string{"some-stack"},
 			URI:      "pipenv-dependency-uri",
 			Version:  "pipenv-dependency-version",
@@ -73,7 +78,15 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		}
 
 		installProcess = &fakes.InstallProcess{}
+		installProcess.ExecuteCall.Stub = func(version, destLayerPath, cachePath string, breakSystemPackages bool) error {
+			distInfo := filepath.Join(destLayerPath, "lib", "python3.8", "site-packages", "pipenv-2026.7.1.dist-info")
+			Expect(os.MkdirAll(distInfo, os.ModePerm)).To(Succeed())
+			return os.WriteFile(filepath.Join(distInfo, "METADATA"), []byte("Metadata-Version: 2.1\nName: pipenv\nVersion: 2026.7.1\n"), 0644)
+		}
+
 		siteProcess = &fakes.SitePackageProcess{}
+		appInstallProcess = &fakes.AppDependencyInstallProcess{}
+		requirements = &fakes.RequirementsExportProcess{}
 
 		// Syft SBOM
 		sbomGenerator = &fakes.SBOMGenerator{}
@@ -88,6 +101,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			dependencyManager,
 			installProcess,
 			siteProcess,
+			appInstallProcess,
+			requirements,
 			sbomGenerator,
 			logEmitter,
 			chronos.DefaultClock,
@@ -117,7 +132,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		result, err := build(buildContext)
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(result.Layers).To(HaveLen(1))
+		Expect(result.Layers).To(HaveLen(2))
 		layer := result.Layers[0]
 
 		Expect(layer.Name).To(Equal("pipenv"))
@@ -134,7 +149,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 		Expect(layer.Build).To(BeFalse())
 		Expect(layer.Launch).To(BeFalse())
-		Expect(layer.Cache).To(BeFalse())
+		Expect(layer.ExecD).To(BeEmpty())
+		Expect(layer.Cache).To(BeTrue())
 
 		Expect(layer.Metadata).To(HaveLen(1))
 		Expect(layer.Metadata["dependency_checksum"]).To(Equal("pipenv-dependency-sha"))
@@ -156,16 +172,142 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				ID:       "pipenv",
 				Name:     "pipenv-dependency-name",
 				Checksum: "pipenv-dependency-sha",
+				CPE:      "cpe:2.3:a:pypa:pipenv:pipenv-dependency-version:*:*:*:*:python:*:*",
+				PURL:     "pkg:pypi/pipenv@pipenv-dependency-version?checksum=pipenv-dependency-sha&file_name=pipenv-dependency-uri",
 				Stacks:   []string{"some-stack"},
 				URI:      "pipenv-dependency-uri",
 				Version:  "pipenv-dependency-version",
 			},
 		}))
 
+		Expect(sbomGenerator.GenerateFromDependencyCall.Receives.Dependency.PURL).To(Equal("pkg:pypi/pipenv@pipenv-dependency-version?checksum=pipenv-dependency-sha&file_name=pipenv-dependency-uri"))
 		Expect(sbomGenerator.GenerateFromDependencyCall.Receives.Dir).To(Equal(filepath.Join(layersDir, "pipenv")))
 
 		Expect(installProcess.ExecuteCall.Receives.Version).To(ContainSubstring("pipenv-dependency-version"))
 		Expect(installProcess.ExecuteCall.Receives.DestLayerPath).To(Equal(filepath.Join(layersDir, "pipenv")))
+		Expect(installProcess.ExecuteCall.Receives.CachePath).To(Equal(filepath.Join(layersDir, "download-cache")))
+		Expect(installProcess.ExecuteCall.Receives.BreakSystemPackages).To(BeFalse())
+
+		cacheLayer := result.Layers[1]
+		Expect(cacheLayer.Name).To(Equal("download-cache"))
+		Expect(cacheLayer.Build).To(BeFalse())
+		Expect(cacheLayer.Launch).To(BeFalse())
+		Expect(cacheLayer.Cache).To(BeTrue())
+		Expect(filepath.Join(layersDir, "download-cache", "pip")).To(BeADirectory())
+		Expect(filepath.Join(layersDir, "download-cache", "pipenv")).To(BeADirectory())
+
+		Expect(appInstallProcess.ExecuteCall.CallCount).To(Equal(0))
+
+		report, err := pipenv.ReadBuildReport(filepath.Join(layersDir, "pipenv", "build-report.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.SchemaVersion).To(Equal(pipenv.BuildReportSchemaVersion))
+		Expect(report.Dependency).To(Equal(pipenv.BuildReportDependency{
+			ID:       "pipenv",
+			Name:     "pipenv-dependency-name",
+			Version:  "pipenv-dependency-version",
+			Checksum: "pipenv-dependency-sha",
+			PURL:     "pkg:pypi/pipenv@pipenv-dependency-version?checksum=pipenv-dependency-sha&file_name=pipenv-dependency-uri",
+		}))
+		Expect(report.Layer).To(Equal(pipenv.BuildReportLayer{
+			Name:        "pipenv",
+			Path:        filepath.Join(layersDir, "pipenv"),
+			Reused:      false,
+			ReuseReason: "no cached layer",
+		}))
+		Expect(report.SitePackages).To(Equal(filepath.Join(layersDir, "pipenv", "lib", "python3.8", "site-packages")))
+		Expect(report.Packages).To(Equal([]pipenv.InstalledPackage{
+			{Name: "pipenv", Version: "2026.7.1"},
+		}))
+	})
+
+	context("when pip writes console scripts with absolute interpreter paths", func() {
+		it.Before(func() {
+			pythonDir := t.TempDir()
+			Expect(os.WriteFile(filepath.Join(pythonDir, "python3"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+			t.Setenv("PATH", pythonDir)
+
+			installProcess.ExecuteCall.Stub = func(version, destLayerPath, cachePath string, breakSystemPackages bool) error {
+				Expect(os.MkdirAll(filepath.Join(destLayerPath, "bin"), os.ModePerm)).To(Succeed())
+				return os.WriteFile(filepath.Join(destLayerPath, "bin", "pipenv"), []byte("#!/layers/paketo-buildpacks_cpython/cpython/bin/python3\nimport sys\n"), 0755)
+			}
+		})
+
+		it("rewrites them to find python on $PATH", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "pipenv", "bin", "pipenv"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("#!/usr/bin/env python3\nimport sys\n"))
+
+			Expect(buffer.String()).To(ContainSubstring(`Rewrote pipenv to use "#!/usr/bin/env python3"`))
+		})
+	})
+
+	context("when BP_PIPENV_REPORT_PATH is set", func() {
+		var reportPath string
+
+		it.Before(func() {
+			reportPath = filepath.Join(t.TempDir(), "reports", "pipenv.json")
+			t.Setenv("BP_PIPENV_REPORT_PATH", reportPath)
+
+			buildContext.Plan.Entries[0].Metadata = map[string]interface{}{
+				"version":        "1.2.3",
+				"version-source": "BP_PIPENV_VERSION",
+			}
+		})
+
+		it("also writes the build report to that path", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			report, err := pipenv.ReadBuildReport(reportPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.VersionSource).To(Equal("BP_PIPENV_VERSION"))
+			Expect(report.Dependency.Version).To(Equal("pipenv-dependency-version"))
+		})
+	})
+
+	context("when the version is set in pyproject.toml and another entry is unversioned", func() {
+		it.Before(func() {
+			buildContext.Plan.Entries = []packit.BuildpackPlanEntry{
+				{
+					Name:     "pipenv",
+					Metadata: map[string]interface{}{},
+				},
+				{
+					Name: "pipenv",
+					Metadata: map[string]interface{}{
+						"version":        "2024.0.1",
+						"version-source": "pyproject.toml",
+					},
+				},
+			}
+		})
+
+		it("resolves the version from pyproject.toml", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("2024.0.1"))
+
+			report, err := pipenv.ReadBuildReport(filepath.Join(layersDir, "pipenv", "build-report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.VersionSource).To(Equal("pyproject.toml"))
+		})
+
+		context("when the version is set in project.toml instead", func() {
+			it.Before(func() {
+				buildContext.Plan.Entries[1].Metadata["version-source"] = "project.toml"
+			})
+
+			it("resolves the version from project.toml", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("2024.0.1"))
+			})
+		})
 	})
 
 	context("when build plan entries require pipenv at build/launch", func() {
@@ -179,7 +321,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			result, err := build(buildContext)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(result.Layers).To(HaveLen(1))
+			Expect(result.Layers).To(HaveLen(2))
 			layer := result.Layers[0]
 
 			Expect(layer.Name).To(Equal("pipenv"))
@@ -187,6 +329,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(layer.Build).To(BeTrue())
 			Expect(layer.Launch).To(BeTrue())
 			Expect(layer.Cache).To(BeTrue())
+			Expect(layer.ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "pythonpath")}))
 
 			Expect(result.Build.BOM).To(Equal(
 				[]packit.BOMEntry{
@@ -220,6 +363,60 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				},
 			))
 
+			Expect(result.Launch.Labels).To(Equal(map[string]string{
+				"io.paketo.pipenv.version":         "pipenv-dependency-version",
+				"io.paketo.pipenv.source-checksum": "pipenv-dependency-sha",
+				"io.paketo.pipenv.purl":            "pkg:pypi/pipenv@pipenv-dependency-version?checksum=pipenv-dependency-sha&file_name=pipenv-dependency-uri",
+			}))
+		})
+
+		context("when the Pipfile has scripts", func() {
+			it.Before(func() {
+				buildContext.WorkingDir = t.TempDir()
+				Expect(os.WriteFile(filepath.Join(buildContext.WorkingDir, "Pipfile"), []byte(`[scripts]
+web = "gunicorn app:app"
+worker = "celery -A tasks worker"
+`), 0644)).To(Succeed())
+			})
+
+			it("contributes a launch process per script", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes).To(Equal([]packit.Process{
+					{Type: "web", Command: "pipenv", Args: []string{"run", "web"}, Direct: true, Default: true},
+					{Type: "worker", Command: "pipenv", Args: []string{"run", "worker"}, Direct: true},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Assigning launch processes:"))
+			})
+		})
+
+		context("when BP_PIPENV_IMAGE_LABEL_PREFIX is set", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_IMAGE_LABEL_PREFIX", "com.example.pipenv")
+			})
+
+			it("prefixes the labels with it", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Labels).To(HaveKeyWithValue("com.example.pipenv.version", "pipenv-dependency-version"))
+				Expect(result.Launch.Labels).NotTo(HaveKey("io.paketo.pipenv.version"))
+			})
+		})
+
+		context("when BP_PIPENV_IMAGE_LABELS is false", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_IMAGE_LABELS", "false")
+			})
+
+			it("does not add labels", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Labels).To(BeEmpty())
+			})
 		})
 	})
 
@@ -234,13 +431,18 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			buildContext.Plan.Entries[0].Metadata = make(map[string]interface{})
 			buildContext.Plan.Entries[0].Metadata["build"] = true
 			buildContext.Plan.Entries[0].Metadata["launch"] = false
+
+			previous := pipenv.NewBuildReport(postal.Dependency{}, "")
+			previous.SitePackages = "some-site-packages"
+			previous.Packages = []pipenv.InstalledPackage{{Name: "pipenv", Version: "1.2.3"}}
+			Expect(previous.Write(filepath.Join(layersDir, "pipenv", "build-report.json"))).To(Succeed())
 		})
 
 		it("skips the build process if the cached dependency sha matches the selected dependency sha", func() {
 			result, err := build(buildContext)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(result.Layers).To(HaveLen(1))
+			Expect(result.Layers).To(HaveLen(2))
 			layer := result.Layers[0]
 
 			Expect(layer.Name).To(Equal("pipenv"))
@@ -253,9 +455,767 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
 		})
+
+		it("records the reuse and lists the installed packages again", func() {
+			distInfo := filepath.Join(layersDir, "pipenv", "lib", "python3.8", "site-packages", "pipenv-2026.7.1.dist-info")
+			Expect(os.MkdirAll(distInfo, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(distInfo, "METADATA"), []byte("Metadata-Version: 2.1\nName: pipenv\nVersion: 2026.7.1\n"), 0644)).To(Succeed())
+
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(siteProcess.ExecuteCall.Receives.TargetLayerPath).To(Equal(filepath.Join(layersDir, "pipenv")))
+
+			report, err := pipenv.ReadBuildReport(filepath.Join(layersDir, "pipenv", "build-report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Dependency.Checksum).To(Equal("pipenv-dependency-sha"))
+			Expect(report.Layer.Reused).To(BeTrue())
+			Expect(report.Layer.ReuseReason).To(Equal("cached dependency checksum matches the selected dependency"))
+			Expect(report.SitePackages).To(Equal(filepath.Join(layersDir, "pipenv", "lib", "python3.8", "site-packages")))
+			Expect(report.Packages).To(Equal([]pipenv.InstalledPackage{{Name: "pipenv", Version: "2026.7.1"}}))
+			Expect(report.Durations).To(Equal(pipenv.BuildReportDurations{}))
+		})
+
+		context("when pipenv is required at launch", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(layersDir, "pipenv", "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, "pipenv", "bin", "pipenv"), []byte("#!/usr/bin/env python3\n"), 0755)).To(Succeed())
+
+				buildContext.Plan.Entries[0].Metadata["launch"] = true
+			})
+
+			it("adds the exec.d helper to the restored contents of the layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				layer := result.Layers[0]
+				Expect(layer.Launch).To(BeTrue())
+				Expect(layer.Cache).To(BeTrue())
+				Expect(layer.ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "pythonpath")}))
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+				Expect(filepath.Join(layersDir, "pipenv", "bin", "pipenv")).To(BeARegularFile())
+			})
+		})
+
+		context("when the contents of the layer were not restored", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(layersDir, "pipenv"))).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, "pipenv"), os.ModePerm)).To(Succeed())
+
+				buildContext.Plan.Entries[0].Metadata["build"] = false
+				buildContext.Plan.Entries[0].Metadata["launch"] = true
+			})
+
+			it("installs pipenv again into a cached layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				layer := result.Layers[0]
+				Expect(layer.Launch).To(BeTrue())
+				Expect(layer.Cache).To(BeTrue())
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+				Expect(filepath.Join(layersDir, "pipenv", "lib", "python3.8", "site-packages", "pipenv-2026.7.1.dist-info")).To(BeADirectory())
+
+				report, err := pipenv.ReadBuildReport(filepath.Join(layersDir, "pipenv", "build-report.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Layer.Reused).To(BeFalse())
+				Expect(report.Layer.ReuseReason).To(Equal("cached layer contents were not restored"))
+			})
+		})
+	})
+
+	context("when the app configures the buildpack in project.toml", func() {
+		it.Before(func() {
+			workingDir := t.TempDir()
+			Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[io.paketo.pipenv]
+break-system-packages = true
+`), 0644)).To(Succeed())
+			buildContext.WorkingDir = workingDir
+
+			logEmitter = scribe.NewEmitter(buffer).WithLevel("DEBUG")
+			build = pipenv.Build(dependencyManager, installProcess, siteProcess, appInstallProcess, requirements, sbomGenerator, logEmitter, chronos.DefaultClock)
+		})
+
+		it("installs pipenv with that configuration and logs it", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installProcess.ExecuteCall.Receives.BreakSystemPackages).To(BeTrue())
+			Expect(buffer.String()).To(ContainSubstring("BP_PIPENV_BREAK_SYSTEM_PACKAGES = true (project.toml)"))
+		})
+	})
+
+	context("when BP_PIPENV_INSTALL_APP_DEPENDENCIES is true", func() {
+		var workingDir string
+
+		it.Before(func() {
+			t.Setenv("BP_PIPENV_INSTALL_APP_DEPENDENCIES", "true")
+			siteProcess.PythonVersionCall.Returns.String = "3.12.4"
+
+			workingDir = t.TempDir()
+			Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte(`{"_meta": {}}`), 0644)).To(Succeed())
+			buildContext.WorkingDir = workingDir
+		})
+
+		it("installs the application dependencies into a launch layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(3))
+			Expect(result.Layers[0].Name).To(Equal("pipenv"))
+			Expect(result.Layers[0].Build).To(BeTrue())
+			Expect(result.Layers[0].Cache).To(BeTrue())
+
+			layer := result.Layers[1]
+			Expect(layer.Name).To(Equal("packages"))
+			Expect(layer.Path).To(Equal(filepath.Join(layersDir, "packages")))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(layer.Build).To(BeFalse())
+			Expect(layer.Cache).To(BeFalse())
+			Expect(layer.SharedEnv).To(Equal(packit.Environment{
+				"VIRTUAL_ENV.override": filepath.Join(layersDir, "packages"),
+			}))
+			Expect(layer.Metadata).To(HaveKeyWithValue("lockfile_checksum", "bcfe9850f146cb0267b4f798e4b4c44aad84ef937a8533cf7e5b21143691e40a"))
+			Expect(layer.Metadata).To(HaveKeyWithValue("python_version", "3.12.4"))
+
+			Expect(appInstallProcess.ExecuteCall.Receives.WorkingDir).To(Equal(workingDir))
+			Expect(appInstallProcess.ExecuteCall.Receives.PipenvLayerPath).To(Equal(filepath.Join(layersDir, "pipenv")))
+			Expect(appInstallProcess.ExecuteCall.Receives.SitePackagesPath).To(Equal(filepath.Join(layersDir, "pipenv", "lib", "python3.8", "site-packages")))
+			Expect(appInstallProcess.ExecuteCall.Receives.TargetLayerPath).To(Equal(filepath.Join(layersDir, "packages")))
+			Expect(appInstallProcess.ExecuteCall.Receives.CachePath).To(Equal(filepath.Join(layersDir, "download-cache")))
+
+			Expect(buffer.String()).To(ContainSubstring("Installing application dependencies"))
+		})
+
+		context("when pipenv is only required at launch and its layer is reused", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "pipenv.toml"), []byte(`[metadata]
+dependency_checksum = "pipenv-dependency-sha"
+`), 0600)).To(Succeed())
+				Expect(pipenv.NewBuildReport(postal.Dependency{}, "").Write(filepath.Join(layersDir, "pipenv", "build-report.json"))).To(Succeed())
+
+				buildContext.Plan.Entries[0].Metadata = map[string]interface{}{"launch": true}
+			})
+
+			it("keeps the pipenv layer available at build time and cached", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+
+				layer := result.Layers[0]
+				Expect(layer.Name).To(Equal("pipenv"))
+				Expect(layer.Launch).To(BeTrue())
+				Expect(layer.Build).To(BeTrue())
+				Expect(layer.Cache).To(BeTrue())
+
+				Expect(appInstallProcess.ExecuteCall.Receives.PipenvLayerPath).To(Equal(filepath.Join(layersDir, "pipenv")))
+			})
+		})
+
+		context("when a downstream buildpack requires site-packages at build time", func() {
+			it.Before(func() {
+				buildContext.Plan.Entries = append(buildContext.Plan.Entries, packit.BuildpackPlanEntry{
+					Name:     "site-packages",
+					Metadata: map[string]interface{}{"build": true},
+				})
+			})
+
+			it("makes the layer available at build time", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(3))
+				layer := result.Layers[1]
+				Expect(layer.Launch).To(BeTrue())
+				Expect(layer.Build).To(BeTrue())
+				Expect(layer.Cache).To(BeTrue())
+			})
+		})
+
+		context("when the Pipfile.lock has not changed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "packages.toml"), []byte(`[metadata]
+				lockfile_checksum = "bcfe9850f146cb0267b4f798e4b4c44aad84ef937a8533cf7e5b21143691e40a"
+				python_version = "3.12.4"
+				`), os.ModePerm)).To(Succeed())
+			})
+
+			it("reuses the cached layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(3))
+				Expect(result.Layers[1].Name).To(Equal("packages"))
+				Expect(result.Layers[1].Launch).To(BeTrue())
+
+				Expect(appInstallProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+
+			context("when the python version has changed", func() {
+				it.Before(func() {
+					siteProcess.PythonVersionCall.Returns.String = "3.13.0"
+				})
+
+				it("reinstalls the application dependencies", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(appInstallProcess.ExecuteCall.CallCount).To(Equal(1))
+					Expect(result.Layers[1].Metadata).To(HaveKeyWithValue("python_version", "3.13.0"))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when there is no Pipfile.lock", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "Pipfile.lock"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("installing application dependencies requires a Pipfile.lock")))
+				})
+			})
+
+			context("when the python version cannot be determined", func() {
+				it.Before(func() {
+					siteProcess.PythonVersionCall.Returns.Error = errors.New("failed to determine python version")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to determine python version")))
+				})
+			})
+
+			context("when the application dependencies cannot be installed", func() {
+				it.Before(func() {
+					appInstallProcess.ExecuteCall.Returns.Error = errors.New("failed to install application dependencies")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to install application dependencies")))
+				})
+			})
+
+			context("when the setting is not a boolean", func() {
+				it.Before(func() {
+					t.Setenv("BP_PIPENV_INSTALL_APP_DEPENDENCIES", "sometimes")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`invalid value for $BP_PIPENV_INSTALL_APP_DEPENDENCIES: "sometimes" is not a boolean`))
+				})
+			})
+		})
+	})
+
+	context("when BP_PIPENV_EXPORT_REQUIREMENTS is true", func() {
+		var workingDir string
+
+		it.Before(func() {
+			t.Setenv("BP_PIPENV_EXPORT_REQUIREMENTS", "true")
+
+			workingDir = t.TempDir()
+			Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte(`{"_meta": {}}`), 0644)).To(Succeed())
+			buildContext.WorkingDir = workingDir
+		})
+
+		it("exports the requirements into a build layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(3))
+			Expect(result.Layers[0].Name).To(Equal("pipenv"))
+			Expect(result.Layers[0].Build).To(BeTrue())
+			Expect(result.Layers[0].Cache).To(BeTrue())
+
+			layer := result.Layers[1]
+			Expect(layer.Name).To(Equal("requirements"))
+			Expect(layer.Launch).To(BeFalse())
+			Expect(layer.Build).To(BeTrue())
+			Expect(layer.Cache).To(BeTrue())
+			Expect(layer.BuildEnv).To(Equal(packit.Environment{
+				"BP_PIP_REQUIREMENT.default": filepath.Join(layersDir, "requirements", "requirements.txt"),
+			}))
+			Expect(layer.Metadata).To(Equal(map[string]interface{}{
+				"lockfile_checksum": "bcfe9850f146cb0267b4f798e4b4c44aad84ef937a8533cf7e5b21143691e40a",
+				"options":           "dev=false categories=",
+				"requirements_file": filepath.Join(layersDir, "requirements", "requirements.txt"),
+			}))
+
+			Expect(requirements.ExecuteCall.Receives.WorkingDir).To(Equal(workingDir))
+			Expect(requirements.ExecuteCall.Receives.PipenvLayerPath).To(Equal(filepath.Join(layersDir, "pipenv")))
+			Expect(requirements.ExecuteCall.Receives.SitePackagesPath).To(Equal(filepath.Join(layersDir, "pipenv", "lib", "python3.8", "site-packages")))
+			Expect(requirements.ExecuteCall.Receives.OutputPath).To(Equal(filepath.Join(layersDir, "requirements", "requirements.txt")))
+			Expect(requirements.ExecuteCall.Receives.Dev).To(BeFalse())
+			Expect(requirements.ExecuteCall.Receives.Categories).To(BeEmpty())
+		})
+
+		context("when dev packages and categories are selected", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_REQUIREMENTS_DEV", "true")
+				t.Setenv("BP_PIPENV_REQUIREMENTS_CATEGORIES", "packages docs")
+			})
+
+			it("passes the selection to pipenv", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(requirements.ExecuteCall.Receives.Dev).To(BeTrue())
+				Expect(requirements.ExecuteCall.Receives.Categories).To(Equal([]string{"packages", "docs"}))
+			})
+		})
+
+		context("when neither Pipfile.lock nor the options have changed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "requirements.toml"), []byte(`[metadata]
+				lockfile_checksum = "bcfe9850f146cb0267b4f798e4b4c44aad84ef937a8533cf7e5b21143691e40a"
+				options = "dev=false categories="
+				`), os.ModePerm)).To(Succeed())
+			})
+
+			it("reuses the cached layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[1].Name).To(Equal("requirements"))
+				Expect(result.Layers[1].Build).To(BeTrue())
+				Expect(requirements.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when there is no Pipfile.lock", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "Pipfile.lock"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("exporting requirements requires a Pipfile.lock")))
+				})
+			})
+
+			context("when the export fails", func() {
+				it.Before(func() {
+					requirements.ExecuteCall.Returns.Error = errors.New("failed to export requirements")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to export requirements")))
+				})
+			})
+		})
+	})
+
+	context("when the download cache has entries", func() {
+		var (
+			oldEntry    string
+			recentEntry string
+		)
+
+		it.Before(func() {
+			cacheDir := filepath.Join(layersDir, "download-cache", "pip", "http")
+			Expect(os.MkdirAll(cacheDir, os.ModePerm)).To(Succeed())
+
+			oldEntry = filepath.Join(cacheDir, "old-entry")
+			Expect(os.WriteFile(oldEntry, []byte("some-old-content"), 0644)).To(Succeed())
+			Expect(os.Chtimes(oldEntry, time.Now().Add(-60*24*time.Hour), time.Now().Add(-60*24*time.Hour))).To(Succeed())
+
+			recentEntry = filepath.Join(cacheDir, "recent-entry")
+			Expect(os.WriteFile(recentEntry, []byte("some-recent-content"), 0644)).To(Succeed())
+		})
+
+		it("prunes the entries older than the maximum age", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(oldEntry).NotTo(BeAnExistingFile())
+			Expect(recentEntry).To(BeAnExistingFile())
+
+			Expect(buffer.String()).To(ContainSubstring("Using download cache"))
+			Expect(buffer.String()).To(ContainSubstring("2 entries"))
+			Expect(buffer.String()).To(ContainSubstring("Pruned 1 entries"))
+		})
+
+		context("when BP_PIPENV_CACHE_MAX_SIZE is exceeded", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_CACHE_MAX_AGE", "2000h")
+				t.Setenv("BP_PIPENV_CACHE_MAX_SIZE", "0")
+			})
+
+			it("prunes entries until the cache fits", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(oldEntry).NotTo(BeAnExistingFile())
+				Expect(recentEntry).NotTo(BeAnExistingFile())
+				Expect(buffer.String()).To(ContainSubstring("Pruned 2 entries"))
+			})
+		})
+	})
+
+	context("when the cached layer was built from a different dependency", func() {
+		it.Before(func() {
+			err := os.WriteFile(filepath.Join(layersDir, fmt.Sprintf("%s.toml", pipenv.Pipenv)), []byte(fmt.Sprintf(`[metadata]
+			%s = "some-other-sha"
+			`, pipenv.DependencyChecksumKey)), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("records why the layer was not reused", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			report, err := pipenv.ReadBuildReport(filepath.Join(layersDir, "pipenv", "build-report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Layer.Reused).To(BeFalse())
+			Expect(report.Layer.ReuseReason).To(Equal("cached dependency checksum some-other-sha does not match the selected dependency"))
+		})
+	})
+
+	context("when the dependencies in buildpack.toml declare requires-python", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2026.7.0"
+  stacks = ["*"]
+  requires-python = ">=3.9"
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2026.8.0"
+  stacks = ["*"]
+  requires-python = ">=3.10"
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2026.9.0"
+  stacks = ["other-stack"]
+  requires-python = ">=3.9"
+`), 0644)).To(Succeed())
+
+			siteProcess.PythonVersionCall.Returns.String = "3.9.18"
+		})
+
+		it("resolves the newest version compatible with the CPython in use", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("2026.7.0"))
+			Expect(buffer.String()).To(ContainSubstring("Skipping Pipenv 2026.8.0: requires Python >=3.10, found CPython 3.9.18"))
+			Expect(buffer.String()).To(ContainSubstring("Selected Pipenv 2026.7.0, the newest version compatible with CPython 3.9.18"))
+
+			report, err := pipenv.ReadBuildReport(filepath.Join(layersDir, "pipenv", "build-report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.SkippedVersions).To(Equal([]pipenv.SkippedVersion{
+				{Version: "2026.8.0", RequiresPython: ">=3.10"},
+			}))
+		})
+
+		context("when the newest version is compatible", func() {
+			it.Before(func() {
+				siteProcess.PythonVersionCall.Returns.String = "3.12.4"
+			})
+
+			it("leaves the resolution to the dependency manager", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal(""))
+				Expect(buffer.String()).NotTo(ContainSubstring("Skipping Pipenv"))
+			})
+		})
+
+		context("when the newest version is a pre-release", func() {
+			it.Before(func() {
+				content, err := os.ReadFile(filepath.Join(cnbDir, "buildpack.toml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), append(content, []byte(`
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2026.8.1rc1"
+  stacks = ["*"]
+  requires-python = ">=3.9"
+`)...), 0644)).To(Succeed())
+			})
+
+			it("skips it", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("2026.7.0"))
+			})
+
+			context("when BP_PIPENV_ALLOW_PRERELEASE is true", func() {
+				it.Before(func() {
+					t.Setenv("BP_PIPENV_ALLOW_PRERELEASE", "true")
+				})
+
+				it("selects it", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal(""))
+					Expect(buffer.String()).NotTo(ContainSubstring("Skipping Pipenv"))
+				})
+			})
+		})
+
+		context("when a newer version is only built for another platform", func() {
+			it.Before(func() {
+				t.Setenv("CNB_TARGET_OS", "linux")
+				t.Setenv("CNB_TARGET_ARCH", "amd64")
+
+				content, err := os.ReadFile(filepath.Join(cnbDir, "buildpack.toml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), append(content, []byte(`
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2026.7.5"
+  stacks = ["*"]
+  os = "linux"
+  arch = "arm64"
+  requires-python = ">=3.9"
+`)...), 0644)).To(Succeed())
+			})
+
+			it("does not select it", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("2026.7.0"))
+			})
+		})
+
+		context("when a version is requested", func() {
+			it.Before(func() {
+				buildContext.Plan.Entries[0].Metadata = map[string]interface{}{"version": "2026.8.0"}
+			})
+
+			it("does not check the CPython version", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("2026.8.0"))
+				Expect(siteProcess.PythonVersionCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the CPython version cannot be determined", func() {
+			it.Before(func() {
+				siteProcess.PythonVersionCall.Returns.Error = errors.New("no python")
+			})
+
+			it("warns and leaves the resolution to the dependency manager", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal(""))
+				Expect(buffer.String()).To(ContainSubstring("WARNING: not checking Requires-Python, the CPython version is unknown: no python"))
+			})
+		})
+
+		context("when no version is compatible", func() {
+			it.Before(func() {
+				siteProcess.PythonVersionCall.Returns.String = "3.8.10"
+			})
+
+			it("warns and leaves the resolution to the dependency manager", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal(""))
+				Expect(buffer.String()).To(ContainSubstring("Skipping Pipenv 2026.7.0: requires Python >=3.9, found CPython 3.8.10"))
+				Expect(buffer.String()).To(ContainSubstring("WARNING: no Pipenv version supports CPython 3.8.10"))
+			})
+		})
+	})
+
+	context("when a vulnerability database is supplied", func() {
+		it.Before(func() {
+			databasePath := filepath.Join(t.TempDir(), "osv.json")
+			Expect(os.WriteFile(databasePath, []byte(`{
+				"id": "GHSA-some-advisory",
+				"affected": [{
+					"package": {"ecosystem": "PyPI", "name": "pipenv"},
+					"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "2026.8.0"}]}]
+				}],
+				"database_specific": {"severity": "HIGH"}
+			}`), 0644)).To(Succeed())
+
+			t.Setenv("BP_PIPENV_VULNERABILITY_DB", databasePath)
+		})
+
+		it("fails the build and records the findings in the build report", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(ContainSubstring("found 1 known vulnerabilities at or above the \"high\" severity threshold")))
+
+			report, err := pipenv.ReadBuildReport(filepath.Join(layersDir, "pipenv", "build-report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Vulnerabilities).To(Equal([]pipenv.VulnerabilityFinding{
+				{
+					ID:            "GHSA-some-advisory",
+					Package:       "pipenv",
+					Version:       "2026.7.1",
+					Source:        "installed",
+					Severity:      "HIGH",
+					FixedVersions: []string{"2026.8.0"},
+				},
+			}))
+		})
+
+		context("when the findings are below BP_PIPENV_VULNERABILITY_THRESHOLD", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_VULNERABILITY_THRESHOLD", "critical")
+			})
+
+			it("reports the findings without failing the build", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				report, err := pipenv.ReadBuildReport(filepath.Join(layersDir, "pipenv", "build-report.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Vulnerabilities).To(HaveLen(1))
+
+				Expect(buffer.String()).To(ContainSubstring("Scanning for known vulnerabilities"))
+				Expect(buffer.String()).To(ContainSubstring("pipenv 2026.7.1 (installed): GHSA-some-advisory [HIGH]"))
+			})
+		})
+	})
+
+	context("when a license policy is configured", func() {
+		it.Before(func() {
+			dependencyManager.ResolveCall.Returns.Dependency.Licenses = []string{"MIT", "MIT-0"}
+			t.Setenv("BP_PIPENV_LICENSE_DENY", "MIT-0")
+		})
+
+		it("fails the build listing the offending packages", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError("1 packages violate the license policy:\n  pipenv (buildpack.toml) pipenv-dependency-version (MIT AND MIT-0): license is denied"))
+		})
+
+		context("when BP_PIPENV_LICENSE_CHECK is warn", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_LICENSE_CHECK", "warn")
+			})
+
+			it("logs a warning", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Checking package licenses"))
+				Expect(buffer.String()).To(ContainSubstring("WARNING: 1 packages violate the license policy"))
+			})
+		})
+
+		context("when the pipenv layer is reused", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_LICENSE_DENY", "GPL-3.0-only")
+
+				Expect(os.WriteFile(filepath.Join(layersDir, "pipenv.toml"), []byte(`[metadata]
+dependency_checksum = "pipenv-dependency-sha"
+`), 0600)).To(Succeed())
+				Expect(pipenv.NewBuildReport(postal.Dependency{}, "").Write(filepath.Join(layersDir, "pipenv", "build-report.json"))).To(Succeed())
+
+				distInfo := filepath.Join(layersDir, "pipenv", "lib", "python3.8", "site-packages", "some-package-1.0.0.dist-info")
+				Expect(os.MkdirAll(distInfo, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(distInfo, "METADATA"), []byte("Metadata-Version: 2.1\nName: some-package\nVersion: 1.0.0\nLicense-Expression: GPL-3.0-only\n"), 0644)).To(Succeed())
+			})
+
+			it("checks the packages installed into the layer", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("some-package 1.0.0 (GPL-3.0-only): license is denied")))
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
 	})
 
 	context("failure cases", func() {
+		context("when BP_PIPENV_LOCK_CHECK is fail and Pipfile.lock is out of date", func() {
+			it.Before(func() {
+				workingDir := t.TempDir()
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte("[packages]\nflask = \"*\"\n"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte(`{"_meta": {"hash": {"sha256": "some-stale-hash"}}}`), 0644)).To(Succeed())
+				buildContext.WorkingDir = workingDir
+
+				t.Setenv("BP_PIPENV_LOCK_CHECK", "fail")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("Pipfile.lock is out of date")))
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when BP_PIPENV_VULNERABILITY_THRESHOLD is invalid", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_VULNERABILITY_THRESHOLD", "severe")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("invalid value for $BP_PIPENV_VULNERABILITY_THRESHOLD")))
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when BP_PIPENV_LICENSE_CHECK is invalid", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_LICENSE_CHECK", "strict")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`invalid license check mode "strict"`)))
+			})
+		})
+
+		context("when buildpack.toml is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte("[[metadata.dependencies"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse buildpack.toml")))
+			})
+		})
+
+		context("when BP_PIPENV_CACHE_MAX_AGE is invalid", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_CACHE_MAX_AGE", "forever")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("invalid value for $BP_PIPENV_CACHE_MAX_AGE")))
+			})
+		})
+
+		context("when BP_PIPENV_CACHE_MAX_SIZE is invalid", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_CACHE_MAX_SIZE", "lots")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid value for $BP_PIPENV_CACHE_MAX_SIZE: "lots" is not a number of megabytes`))
+			})
+		})
+
 		context("when dependency resolution fails", func() {
 			it.Before(func() {
 				dependencyManager.ResolveCall.Returns.Error = errors.New("failed to resolve dependency")
@@ -302,6 +1262,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 		context("when dependency cannot be installed", func() {
 			it.Before(func() {
+				installProcess.ExecuteCall.Stub = nil
 				installProcess.ExecuteCall.Returns.Error = errors.New("failed to install dependency")
 			})
 			it("returns an error", func() {
@@ -311,6 +1272,69 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when BP_PIPENV_IMAGE_LABEL_PREFIX is not in reverse DNS notation", func() {
+			it.Before(func() {
+				buildContext.Plan.Entries[0].Metadata = map[string]interface{}{"launch": true}
+				t.Setenv("BP_PIPENV_IMAGE_LABEL_PREFIX", "Some Prefix")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid value for $BP_PIPENV_IMAGE_LABEL_PREFIX: "Some Prefix" must be in reverse DNS notation, such as "io.paketo.pipenv"`))
+			})
+		})
+
+		context("when the Pipfile is malformed", func() {
+			it.Before(func() {
+				buildContext.WorkingDir = t.TempDir()
+				Expect(os.WriteFile(filepath.Join(buildContext.WorkingDir, "Pipfile"), []byte("[packages\n"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("invalid Pipfile:\n  Pipfile:1:")))
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when BP_PIPENV_DEFAULT_PROCESS is not a script in the Pipfile", func() {
+			it.Before(func() {
+				buildContext.Plan.Entries[0].Metadata = map[string]interface{}{"launch": true}
+				t.Setenv("BP_PIPENV_DEFAULT_PROCESS", "missing")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`invalid value for $BP_PIPENV_DEFAULT_PROCESS: "missing" is not a script in the Pipfile`)))
+			})
+		})
+
+		context("when BP_PIPENV_IMAGE_LABELS is not a boolean", func() {
+			it.Before(func() {
+				buildContext.Plan.Entries[0].Metadata = map[string]interface{}{"launch": true}
+				t.Setenv("BP_PIPENV_IMAGE_LABELS", "sometimes")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid value for $BP_PIPENV_IMAGE_LABELS: "sometimes" is not a boolean`))
+			})
+		})
+
+		context("when a console script does not resolve", func() {
+			it.Before(func() {
+				installProcess.ExecuteCall.Stub = func(version, destLayerPath, cachePath string, breakSystemPackages bool) error {
+					Expect(os.MkdirAll(filepath.Join(destLayerPath, "bin"), os.ModePerm)).To(Succeed())
+					return os.WriteFile(filepath.Join(destLayerPath, "bin", "pipenv"), []byte("#!/usr/bin/env python3\nimport sys\n"), 0644)
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("pipenv: not executable")))
+			})
+		})
+
 		context("when the site packages cannot be found", func() {
 			it.Before(func() {
 				siteProcess.ExecuteCall.Returns.Error = errors.New("failed to find site-packages dir")
@@ -333,6 +1357,19 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when the build report cannot be written", func() {
+			it.Before(func() {
+				file := filepath.Join(t.TempDir(), "some-file")
+				Expect(os.WriteFile(file, nil, 0644)).To(Succeed())
+				t.Setenv("BP_PIPENV_REPORT_PATH", filepath.Join(file, "report.json"))
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to write build report")))
+			})
+		})
+
 		context("when generating the SBOM returns an error", func() {
 			it.Before(func() {
 				buildContext.BuildpackInfo.SBOMFormats = []string{"random-format"}
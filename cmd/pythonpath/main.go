@@ -0,0 +1,39 @@
+// Command pythonpath is an exec.d executable that recomputes the $PYTHONPATH
+// and $PATH of the pipenv layer when the container starts. It is copied into
+// the exec.d directory of the layer, so the layer is the parent of its
+// directory.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/pipenv"
+)
+
+func main() {
+	executable, err := os.Executable()
+	if err != nil {
+		fail(fmt.Errorf("failed to locate the pipenv layer: %w", err))
+	}
+	layerPath := filepath.Dir(filepath.Dir(executable))
+
+	env, err := pipenv.LaunchEnvironment(layerPath, pipenv.NewSiteProcess(pexec.NewExecutable("python")))
+	if err != nil {
+		fail(err)
+	}
+
+	// exec.d executables report the environment as TOML on file descriptor 3.
+	err = toml.NewEncoder(os.NewFile(3, "/dev/fd/3")).Encode(env)
+	if err != nil {
+		fail(fmt.Errorf("failed to write the launch environment: %w", err))
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "pipenv: %s\n", err)
+	os.Exit(1)
+}
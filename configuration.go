@@ -0,0 +1,344 @@
+package pipenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// ConfigurationDefault is the source of a setting that is not configured.
+const ConfigurationDefault = "default"
+
+// configurationEnvPrefix is the prefix of the environment variables that
+// configure the buildpack.
+const configurationEnvPrefix = "BP_PIPENV_"
+
+// Configuration is the configuration of the buildpack. Each setting is read
+// from its environment variable, from the [tool.paketo.pipenv] table of the
+// app's pyproject.toml, or from the [io.paketo.pipenv] table of its
+// project.toml, in that order of precedence. In those tables, a setting is
+// named after its environment variable in kebab case, without the BP_PIPENV_
+// prefix: $BP_PIPENV_ALLOW_PRERELEASE is allow-prerelease.
+type Configuration struct {
+	Version                   string   `env:"BP_PIPENV_VERSION"`
+	Build                     bool     `env:"BP_PIPENV_BUILD"`
+	Launch                    bool     `env:"BP_PIPENV_LAUNCH"`
+	AllowPrerelease           bool     `env:"BP_PIPENV_ALLOW_PRERELEASE"`
+	BreakSystemPackages       bool     `env:"BP_PIPENV_BREAK_SYSTEM_PACKAGES"`
+	ImageLabels               bool     `env:"BP_PIPENV_IMAGE_LABELS"`
+	ImageLabelPrefix          string   `env:"BP_PIPENV_IMAGE_LABEL_PREFIX"`
+	DefaultProcess            string   `env:"BP_PIPENV_DEFAULT_PROCESS"`
+	ExcludeScripts            []string `env:"BP_PIPENV_EXCLUDE_SCRIPTS" separator:","`
+	ReportPath                string   `env:"BP_PIPENV_REPORT_PATH"`
+	ConflictCheck             string   `env:"BP_PIPENV_CONFLICT_CHECK"`
+	Validation                string   `env:"BP_PIPENV_VALIDATION"`
+	LockCheck                 string   `env:"BP_PIPENV_LOCK_CHECK"`
+	InstallAppDependencies    bool     `env:"BP_PIPENV_INSTALL_APP_DEPENDENCIES"`
+	ExportRequirements        bool     `env:"BP_PIPENV_EXPORT_REQUIREMENTS"`
+	RequirementsDev           bool     `env:"BP_PIPENV_REQUIREMENTS_DEV"`
+	RequirementsCategories    []string `env:"BP_PIPENV_REQUIREMENTS_CATEGORIES" separator:" "`
+	CacheMaxAge               string   `env:"BP_PIPENV_CACHE_MAX_AGE"`
+	CacheMaxSize              string   `env:"BP_PIPENV_CACHE_MAX_SIZE"`
+	VulnerabilityDB           string   `env:"BP_PIPENV_VULNERABILITY_DB"`
+	VulnerabilityThreshold    string   `env:"BP_PIPENV_VULNERABILITY_THRESHOLD"`
+	VulnerabilityScanLockfile bool     `env:"BP_PIPENV_VULNERABILITY_SCAN_LOCKFILE"`
+	LicenseAllow              []string `env:"BP_PIPENV_LICENSE_ALLOW" separator:","`
+	LicenseDeny               []string `env:"BP_PIPENV_LICENSE_DENY" separator:","`
+	LicenseCheck              string   `env:"BP_PIPENV_LICENSE_CHECK"`
+
+	// sources maps the environment variable of each configured setting to
+	// where its value came from.
+	sources map[string]string
+
+	// ignored lists why the configuration files that could not be read were
+	// skipped.
+	ignored []string
+}
+
+// configurationSetting is a field of Configuration, the environment variable
+// and file key that configure it, and, for lists, the separator of their
+// items in the environment variable. A " " separator splits on any
+// whitespace.
+type configurationSetting struct {
+	field     int
+	env       string
+	key       string
+	separator string
+}
+
+// configurationFile is a file in the app that can configure the buildpack,
+// and the path of the table holding the settings.
+type configurationFile struct {
+	name  string
+	table []string
+}
+
+// configurationFiles are the files that configure the buildpack, from the
+// lowest to the highest precedence.
+var configurationFiles = []configurationFile{
+	{name: "project.toml", table: []string{"io", "paketo", "pipenv"}},
+	{name: "pyproject.toml", table: []string{"tool", "paketo", "pipenv"}},
+}
+
+func configurationSettings() []configurationSetting {
+	var settings []configurationSetting
+
+	t := reflect.TypeOf(Configuration{})
+	for i := 0; i < t.NumField(); i++ {
+		env, ok := t.Field(i).Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+
+		settings = append(settings, configurationSetting{
+			field:     i,
+			env:       env,
+			key:       strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(env, configurationEnvPrefix), "_", "-")),
+			separator: t.Field(i).Tag.Get("separator"),
+		})
+	}
+
+	return settings
+}
+
+// LoadConfiguration returns the configuration of the buildpack for the app in
+// workingDir. Values are checked against the type of their setting: booleans
+// must be booleans, lists must be arrays of strings, and the other settings
+// must be strings, which may also be given as integers in the files. In the
+// environment, booleans are parsed with strconv.ParseBool, and lists are
+// split on their separator. An empty environment variable is not set.
+//
+// As the buildpack participates in the builds of apps that do not use Pipenv,
+// a file that cannot be parsed, or that has no table for the buildpack, is
+// skipped (see Log). Only an invalid table is an error, such as one with an
+// unknown setting.
+func LoadConfiguration(workingDir string) (Configuration, error) {
+	config := Configuration{
+		ImageLabels:            true,
+		ImageLabelPrefix:       DefaultImageLabelPrefix,
+		ConflictCheck:          ConflictCheckWarn,
+		Validation:             ValidationFail,
+		LockCheck:              LockCheckOff,
+		VulnerabilityThreshold: DefaultVulnerabilityThreshold,
+		LicenseCheck:           LicenseCheckFail,
+		sources:                map[string]string{},
+	}
+
+	settings := configurationSettings()
+
+	for _, file := range configurationFiles {
+		content, err := readConfigurationFile(filepath.Join(workingDir, file.name))
+		if err != nil {
+			config.ignored = append(config.ignored, fmt.Sprintf("ignoring the settings in %s: %s", file.name, err))
+			continue
+		}
+
+		table, err := configurationTable(content, file.table, file.name)
+		if err != nil {
+			return Configuration{}, err
+		}
+
+		for key, value := range table {
+			name := fmt.Sprintf("%s.%s in %s", strings.Join(file.table, "."), key, file.name)
+
+			setting, ok := findConfigurationSetting(settings, key)
+			if !ok {
+				return Configuration{}, fmt.Errorf("unknown setting %s", name)
+			}
+
+			err = config.setFromFile(setting, value, name)
+			if err != nil {
+				return Configuration{}, err
+			}
+			config.sources[setting.env] = file.name
+		}
+	}
+
+	for _, setting := range settings {
+		value, ok := os.LookupEnv(setting.env)
+		if !ok || value == "" {
+			continue
+		}
+
+		err := config.setFromEnv(setting, value)
+		if err != nil {
+			return Configuration{}, err
+		}
+		config.sources[setting.env] = setting.env
+	}
+
+	return config, nil
+}
+
+// readConfigurationFile returns the content of the TOML file at path. A
+// missing file has no content.
+func readConfigurationFile(path string) (map[string]interface{}, error) {
+	var content map[string]interface{}
+	_, err := toml.DecodeFile(path, &content)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+
+	return content, nil
+}
+
+// configurationTable returns the table at the given path in the content of
+// the named file. There are no settings when the table or one of its parents
+// is missing, or when a parent is not a table, as the file then does not
+// configure the buildpack.
+func configurationTable(content map[string]interface{}, table []string, name string) (map[string]interface{}, error) {
+	for i, key := range table {
+		value, ok := content[key]
+		if !ok {
+			return nil, nil
+		}
+
+		content, ok = value.(map[string]interface{})
+		if !ok {
+			if i < len(table)-1 {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid value for %s in %s: must be a table", strings.Join(table, "."), name)
+		}
+	}
+
+	return content, nil
+}
+
+func findConfigurationSetting(settings []configurationSetting, key string) (configurationSetting, bool) {
+	for _, setting := range settings {
+		if setting.key == key {
+			return setting, true
+		}
+	}
+
+	return configurationSetting{}, false
+}
+
+func (c *Configuration) setFromEnv(setting configurationSetting, value string) error {
+	field := reflect.ValueOf(c).Elem().Field(setting.field)
+
+	switch field.Kind() {
+	case reflect.Bool:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for $%s: %q is not a boolean", setting.env, value)
+		}
+		field.SetBool(enabled)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(splitList(value, setting.separator)))
+	default:
+		field.SetString(value)
+	}
+
+	return nil
+}
+
+func (c *Configuration) setFromFile(setting configurationSetting, value interface{}, name string) error {
+	field := reflect.ValueOf(c).Elem().Field(setting.field)
+
+	switch field.Kind() {
+	case reflect.Bool:
+		enabled, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("invalid value for %s: must be a boolean", name)
+		}
+		field.SetBool(enabled)
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid value for %s: must be an array of strings", name)
+		}
+
+		list := []string{}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("invalid value for %s: must be an array of strings", name)
+			}
+			list = append(list, s)
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		switch v := value.(type) {
+		case string:
+			field.SetString(v)
+		case int64:
+			field.SetString(strconv.FormatInt(v, 10))
+		default:
+			return fmt.Errorf("invalid value for %s: must be a string", name)
+		}
+	}
+
+	return nil
+}
+
+// splitList splits a list given in an environment variable, dropping empty
+// items.
+func splitList(value, separator string) []string {
+	if separator == " " {
+		return strings.Fields(value)
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// Source returns where the setting configured by the named environment
+// variable came from: the environment variable itself, "pyproject.toml",
+// "project.toml" or ConfigurationDefault.
+func (c Configuration) Source(env string) string {
+	if source, ok := c.sources[env]; ok {
+		return source
+	}
+
+	return ConfigurationDefault
+}
+
+// Log warns about the configuration files that were skipped, and logs the
+// effective value and source of each setting at the debug level.
+func (c Configuration) Log(logger scribe.Emitter) {
+	if len(c.ignored) > 0 {
+		logger.Process("Reading configuration")
+		for _, ignored := range c.ignored {
+			logger.Subprocess("WARNING: %s", ignored)
+		}
+		logger.Break()
+	}
+
+	logger.Debug.Process("Configuration:")
+
+	value := reflect.ValueOf(c)
+	for _, setting := range configurationSettings() {
+		field := value.Field(setting.field)
+
+		var formatted string
+		switch field.Kind() {
+		case reflect.Bool:
+			formatted = strconv.FormatBool(field.Bool())
+		case reflect.Slice:
+			formatted = fmt.Sprintf("%q", field.Interface())
+		default:
+			formatted = strconv.Quote(field.String())
+		}
+
+		logger.Debug.Subprocess("%s = %s (%s)", setting.env, formatted, c.Source(setting.env))
+	}
+	logger.Debug.Break()
+}
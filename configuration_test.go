@@ -0,0 +1,282 @@
+package pipenv_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testConfiguration(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+	})
+
+	context("LoadConfiguration", func() {
+		context("when nothing is configured", func() {
+			it("returns the defaults", func() {
+				config, err := pipenv.LoadConfiguration(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(config.Version).To(BeEmpty())
+				Expect(config.Build).To(BeFalse())
+				Expect(config.ImageLabels).To(BeTrue())
+				Expect(config.ImageLabelPrefix).To(Equal("io.paketo.pipenv"))
+				Expect(config.Validation).To(Equal("fail"))
+				Expect(config.ConflictCheck).To(Equal("warn"))
+				Expect(config.LockCheck).To(Equal("off"))
+				Expect(config.LicenseAllow).To(BeNil())
+				Expect(config.Source("BP_PIPENV_VERSION")).To(Equal("default"))
+			})
+		})
+
+		context("when the environment configures the buildpack", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_VERSION", "~=2024.0")
+				t.Setenv("BP_PIPENV_ALLOW_PRERELEASE", "true")
+				t.Setenv("BP_PIPENV_IMAGE_LABELS", "false")
+				t.Setenv("BP_PIPENV_REQUIREMENTS_CATEGORIES", "packages  docs")
+				t.Setenv("BP_PIPENV_LICENSE_ALLOW", "MIT, Apache-2.0,")
+				t.Setenv("BP_PIPENV_LOCK_CHECK", "")
+			})
+
+			it("parses the environment variables", func() {
+				config, err := pipenv.LoadConfiguration(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(config.Version).To(Equal("~=2024.0"))
+				Expect(config.AllowPrerelease).To(BeTrue())
+				Expect(config.ImageLabels).To(BeFalse())
+				Expect(config.RequirementsCategories).To(Equal([]string{"packages", "docs"}))
+				Expect(config.LicenseAllow).To(Equal([]string{"MIT", "Apache-2.0"}))
+				Expect(config.LockCheck).To(Equal("off"))
+
+				Expect(config.Source("BP_PIPENV_VERSION")).To(Equal("BP_PIPENV_VERSION"))
+				Expect(config.Source("BP_PIPENV_LOCK_CHECK")).To(Equal("default"))
+			})
+		})
+
+		context("when pyproject.toml and project.toml configure the buildpack", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[project]
+name = "some-app"
+
+[tool.paketo.pipenv]
+version = "2024.0.1"
+install-app-dependencies = true
+exclude-scripts = ["test", "lint"]
+cache-max-size = 512
+`), 0644)).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[_]
+schema-version = "0.2"
+
+[io.paketo.pipenv]
+version = "2023.12.1"
+lock-check = "warn"
+image-labels = false
+`), 0644)).To(Succeed())
+
+				t.Setenv("BP_PIPENV_LOCK_CHECK", "fail")
+			})
+
+			it("merges them, with the environment over pyproject.toml over project.toml", func() {
+				config, err := pipenv.LoadConfiguration(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(config.Version).To(Equal("2024.0.1"))
+				Expect(config.InstallAppDependencies).To(BeTrue())
+				Expect(config.ExcludeScripts).To(Equal([]string{"test", "lint"}))
+				Expect(config.CacheMaxSize).To(Equal("512"))
+				Expect(config.ImageLabels).To(BeFalse())
+				Expect(config.LockCheck).To(Equal("fail"))
+
+				Expect(config.Source("BP_PIPENV_VERSION")).To(Equal("pyproject.toml"))
+				Expect(config.Source("BP_PIPENV_IMAGE_LABELS")).To(Equal("project.toml"))
+				Expect(config.Source("BP_PIPENV_LOCK_CHECK")).To(Equal("BP_PIPENV_LOCK_CHECK"))
+			})
+		})
+
+		context("when pyproject.toml has no [tool.paketo.pipenv] table", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.black]
+line-length = 100
+`), 0644)).To(Succeed())
+			})
+
+			it("returns the defaults", func() {
+				config, err := pipenv.LoadConfiguration(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Validation).To(Equal("fail"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when a boolean environment variable is not a boolean", func() {
+				it.Before(func() {
+					t.Setenv("BP_PIPENV_BREAK_SYSTEM_PACKAGES", "sometimes")
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LoadConfiguration(workingDir)
+					Expect(err).To(MatchError(`invalid value for $BP_PIPENV_BREAK_SYSTEM_PACKAGES: "sometimes" is not a boolean`))
+				})
+			})
+
+			context("when a setting in a file has the wrong type", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.paketo.pipenv]
+allow-prerelease = "yes"
+`), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LoadConfiguration(workingDir)
+					Expect(err).To(MatchError("invalid value for tool.paketo.pipenv.allow-prerelease in pyproject.toml: must be a boolean"))
+				})
+			})
+
+			context("when a list in a file holds something other than strings", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[io.paketo.pipenv]
+license-deny = ["GPL-3.0-only", 3]
+`), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LoadConfiguration(workingDir)
+					Expect(err).To(MatchError("invalid value for io.paketo.pipenv.license-deny in project.toml: must be an array of strings"))
+				})
+			})
+
+			context("when a string setting in a file is not a string", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.paketo.pipenv]
+validation = false
+`), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LoadConfiguration(workingDir)
+					Expect(err).To(MatchError("invalid value for tool.paketo.pipenv.validation in pyproject.toml: must be a string"))
+				})
+			})
+
+			context("when a file has an unknown setting", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.paketo.pipenv]
+verison = "2024.0.1"
+`), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LoadConfiguration(workingDir)
+					Expect(err).To(MatchError("unknown setting tool.paketo.pipenv.verison in pyproject.toml"))
+				})
+			})
+
+			context("when the settings are not a table", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.paketo]
+pipenv = "2024.0.1"
+`), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LoadConfiguration(workingDir)
+					Expect(err).To(MatchError("invalid value for tool.paketo.pipenv in pyproject.toml: must be a table"))
+				})
+			})
+
+		})
+
+		context("when a file is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte("[tool.paketo.pipenv"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[io.paketo.pipenv]
+build = true
+`), 0644)).To(Succeed())
+			})
+
+			it("skips it and warns about it", func() {
+				config, err := pipenv.LoadConfiguration(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Build).To(BeTrue())
+				Expect(config.Source("BP_PIPENV_BUILD")).To(Equal("project.toml"))
+
+				buffer := bytes.NewBuffer(nil)
+				config.Log(scribe.NewEmitter(buffer))
+				Expect(buffer.String()).To(ContainSubstring("WARNING: ignoring the settings in pyproject.toml: failed to parse pyproject.toml"))
+			})
+		})
+
+		context("when a file does not configure the buildpack", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+tool = "not-a-table"
+
+[project]
+name = "some-app"
+some-key = "some-value"
+`), 0644)).To(Succeed())
+			})
+
+			it("returns the defaults", func() {
+				config, err := pipenv.LoadConfiguration(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Source("BP_PIPENV_VERSION")).To(Equal(pipenv.ConfigurationDefault))
+
+				buffer := bytes.NewBuffer(nil)
+				config.Log(scribe.NewEmitter(buffer))
+				Expect(buffer.String()).To(BeEmpty())
+			})
+		})
+	})
+
+	context("Log", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.paketo.pipenv]
+exclude-scripts = ["test"]
+`), 0644)).To(Succeed())
+
+			t.Setenv("BP_PIPENV_VERSION", "2024.0.1")
+		})
+
+		it("logs the effective configuration and its sources at the debug level", func() {
+			config, err := pipenv.LoadConfiguration(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			buffer := bytes.NewBuffer(nil)
+			config.Log(scribe.NewEmitter(buffer))
+			Expect(buffer.String()).To(BeEmpty())
+
+			config.Log(scribe.NewEmitter(buffer).WithLevel("DEBUG"))
+			Expect(buffer.String()).To(ContainSubstring("Configuration:"))
+			Expect(buffer.String()).To(ContainSubstring(`BP_PIPENV_VERSION = "2024.0.1" (BP_PIPENV_VERSION)`))
+			Expect(buffer.String()).To(ContainSubstring(`BP_PIPENV_EXCLUDE_SCRIPTS = ["test"] (pyproject.toml)`))
+			Expect(buffer.String()).To(ContainSubstring("BP_PIPENV_IMAGE_LABELS = true (default)"))
+		})
+	})
+}
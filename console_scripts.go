@@ -0,0 +1,187 @@
+package pipenv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RelocatableShebang is the interpreter line that console scripts in the
+// pipenv layer are rewritten to, so that they run with the python on $PATH
+// rather than the absolute interpreter path pip wrote at install time.
+const RelocatableShebang = "#!/usr/bin/env python3"
+
+// RelocateConsoleScripts rewrites the console scripts that pip generated in
+// binPath, such as pipenv and pipenv-resolver, to use RelocatableShebang. It
+// handles both the plain "#!/path/to/python" form and the "#!/bin/sh" exec
+// trampoline pip writes when the interpreter path is too long or contains
+// spaces. Other files, and symlinks such as the interpreters of a virtual
+// environment, are left alone. It returns the names of the rewritten scripts.
+func RelocateConsoleScripts(binPath string) ([]string, error) {
+	entries, err := os.ReadDir(binPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read console scripts: %w", err)
+	}
+
+	var relocated []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		path := filepath.Join(binPath, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read console script: %w", err)
+		}
+
+		body, ok := stripPythonShebang(content)
+		if !ok {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read console script: %w", err)
+		}
+
+		err = os.WriteFile(path, append([]byte(RelocatableShebang+"\n"), body...), info.Mode().Perm())
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewrite console script: %w", err)
+		}
+
+		relocated = append(relocated, entry.Name())
+	}
+
+	return relocated, nil
+}
+
+// stripPythonShebang returns the script without its interpreter lines when
+// they run an absolute python path.
+func stripPythonShebang(content []byte) ([]byte, bool) {
+	first, rest, _ := bytes.Cut(content, []byte("\n"))
+	shebang := strings.TrimSpace(string(first))
+
+	if shebang == "#!/bin/sh" {
+		// #!/bin/sh
+		// '''exec' "/path/to/python" "$0" "$@"
+		// ' '''
+		trampoline, rest, _ := bytes.Cut(rest, []byte("\n"))
+		end, rest, _ := bytes.Cut(rest, []byte("\n"))
+		if !strings.HasPrefix(string(trampoline), "'''exec' ") || !strings.Contains(string(trampoline), "python") || strings.TrimSpace(string(end)) != "' '''" {
+			return nil, false
+		}
+
+		return rest, true
+	}
+
+	interpreter, ok := strings.CutPrefix(shebang, "#!")
+	if !ok || !filepath.IsAbs(interpreter) || !strings.HasPrefix(filepath.Base(interpreter), "python") {
+		return nil, false
+	}
+
+	return rest, true
+}
+
+// CheckConsoleScripts confirms that every file in binPath is executable and
+// that the interpreter of each script resolves, either as an absolute path or,
+// for "#!/usr/bin/env" scripts, on $PATH.
+func CheckConsoleScripts(binPath string) error {
+	entries, err := os.ReadDir(binPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read console scripts: %w", err)
+	}
+
+	var problems []string
+	for _, entry := range entries {
+		path := filepath.Join(binPath, entry.Name())
+
+		// Follow symlinks, so that a dangling interpreter symlink is reported.
+		info, err := os.Stat(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", entry.Name(), err))
+			continue
+		}
+
+		if info.IsDir() {
+			continue
+		}
+
+		if info.Mode().Perm()&0111 == 0 {
+			// The activation scripts of a virtual environment are sourced
+			// rather than run.
+			if strings.HasPrefix(entry.Name(), "activate") || strings.HasPrefix(entry.Name(), "Activate") {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s: not executable", entry.Name()))
+			continue
+		}
+
+		if entry.Type()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		err = checkInterpreter(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", entry.Name(), err))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("console scripts in %s do not resolve:\n  %s", binPath, strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// checkInterpreter confirms that the interpreter in the shebang of the
+// script at path, if it has one, can be run.
+func checkInterpreter(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, 256)
+	n, _ := file.Read(header)
+	first, _, _ := bytes.Cut(header[:n], []byte("\n"))
+
+	shebang, ok := strings.CutPrefix(strings.TrimSpace(string(first)), "#!")
+	if !ok {
+		return nil
+	}
+
+	fields := strings.Fields(shebang)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty interpreter line")
+	}
+
+	interpreter := fields[0]
+	if filepath.Base(interpreter) == "env" && len(fields) > 1 {
+		_, err := exec.LookPath(fields[1])
+		if err != nil {
+			return fmt.Errorf("interpreter %q is not on $PATH", fields[1])
+		}
+		return nil
+	}
+
+	info, err := os.Stat(interpreter)
+	if err != nil || info.IsDir() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("interpreter %q cannot be run", interpreter)
+	}
+
+	return nil
+}
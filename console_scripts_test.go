@@ -0,0 +1,133 @@
+package pipenv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testConsoleScripts(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		binPath    string
+		pythonPath string
+	)
+
+	it.Before(func() {
+		binPath = filepath.Join(t.TempDir(), "bin")
+		Expect(os.MkdirAll(binPath, os.ModePerm)).To(Succeed())
+
+		pythonDir := t.TempDir()
+		pythonPath = filepath.Join(pythonDir, "python3")
+		Expect(os.WriteFile(pythonPath, []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+		t.Setenv("PATH", pythonDir)
+	})
+
+	context("RelocateConsoleScripts", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(binPath, "pipenv"), []byte("#!/layers/paketo-buildpacks_cpython/cpython/bin/python3\n# -*- coding: utf-8 -*-\nimport sys\n"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(binPath, "pipenv-resolver"), []byte("#!/bin/sh\n'''exec' \"/some path/with spaces/bin/python3.12\" \"$0\" \"$@\"\n' '''\nimport sys\n"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(binPath, "some-shell-script"), []byte("#!/bin/sh\necho hello\n"), 0755)).To(Succeed())
+			Expect(os.Symlink(pythonPath, filepath.Join(binPath, "python"))).To(Succeed())
+		})
+
+		it("rewrites the console scripts to find python on $PATH", func() {
+			relocated, err := pipenv.RelocateConsoleScripts(binPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(relocated).To(Equal([]string{"pipenv", "pipenv-resolver"}))
+
+			content, err := os.ReadFile(filepath.Join(binPath, "pipenv"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\nimport sys\n"))
+
+			content, err = os.ReadFile(filepath.Join(binPath, "pipenv-resolver"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("#!/usr/bin/env python3\nimport sys\n"))
+
+			info, err := os.Stat(filepath.Join(binPath, "pipenv"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+
+			content, err = os.ReadFile(filepath.Join(binPath, "some-shell-script"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("#!/bin/sh\necho hello\n"))
+
+			target, err := os.Readlink(filepath.Join(binPath, "python"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(target).To(Equal(pythonPath))
+		})
+
+		context("when there is no bin directory", func() {
+			it("does nothing", func() {
+				relocated, err := pipenv.RelocateConsoleScripts(filepath.Join(t.TempDir(), "bin"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(relocated).To(BeEmpty())
+			})
+		})
+	})
+
+	context("CheckConsoleScripts", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(binPath, "pipenv"), []byte("#!/usr/bin/env python3\nimport sys\n"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(binPath, "some-tool"), []byte("#!"+pythonPath+"\nimport sys\n"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(binPath, "activate"), []byte("# source this\n"), 0644)).To(Succeed())
+			Expect(os.Symlink(pythonPath, filepath.Join(binPath, "python"))).To(Succeed())
+		})
+
+		it("succeeds when every script is executable and resolves", func() {
+			Expect(pipenv.CheckConsoleScripts(binPath)).To(Succeed())
+		})
+
+		context("failure cases", func() {
+			context("when a script is not executable", func() {
+				it.Before(func() {
+					Expect(os.Chmod(filepath.Join(binPath, "pipenv"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := pipenv.CheckConsoleScripts(binPath)
+					Expect(err).To(MatchError(ContainSubstring("pipenv: not executable")))
+				})
+			})
+
+			context("when the interpreter of a script is not on $PATH", func() {
+				it.Before(func() {
+					t.Setenv("PATH", t.TempDir())
+				})
+
+				it("returns an error", func() {
+					err := pipenv.CheckConsoleScripts(binPath)
+					Expect(err).To(MatchError(ContainSubstring(`pipenv: interpreter "python3" is not on $PATH`)))
+				})
+			})
+
+			context("when the interpreter of a script does not exist", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(binPath, "some-tool"), []byte("#!/no/such/python3\nimport sys\n"), 0755)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := pipenv.CheckConsoleScripts(binPath)
+					Expect(err).To(MatchError(ContainSubstring(`some-tool: interpreter "/no/such/python3" cannot be run`)))
+				})
+			})
+
+			context("when a symlink dangles", func() {
+				it.Before(func() {
+					Expect(os.Remove(pythonPath)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := pipenv.CheckConsoleScripts(binPath)
+					Expect(err).To(MatchError(ContainSubstring("python: stat")))
+				})
+			})
+		})
+	})
+}
@@ -5,6 +5,14 @@ const (
 	DependencyChecksumKey = "dependency_checksum"
 	CPython               = "cpython"
 	Pip                   = "pip"
+	BuildReportFile       = "build-report.json"
+	SitePackages          = "site-packages"
+	Packages              = "packages"
+	LockfileChecksumKey   = "lockfile_checksum"
+	PythonVersionKey      = "python_version"
+	DownloadCache         = "download-cache"
+	Requirements          = "requirements"
+	PackagingFiles        = "pipenv-packaging-files"
 )
 
-var Priorities = []interface{}{"BP_PIPENV_VERSION"}
+var Priorities = []interface{}{"BP_PIPENV_VERSION", "pyproject.toml", "project.toml"}
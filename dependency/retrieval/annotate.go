@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// requiresPythonKey is the key of the Requires-Python specifier of a
+// dependency in buildpack.toml, read by the buildpack to skip versions that
+// do not support the CPython in use.
+const requiresPythonKey = "requires-python"
+
+// annotateBuildpackToml sets the requires-python key of each id dependency in
+// the buildpack.toml at path to the Requires-Python specifier of its release.
+//
+// The update-from-metadata action decodes metadata.json into
+// cargo.ConfigMetadataDependency and re-encodes buildpack.toml from it, which
+// drops the key from the new versions and from the existing ones alike. This
+// runs after it and sets the key of every version again. The file is edited
+// line by line so that the rest of it keeps the formatting of the action.
+// The key is removed from releases that do not declare Requires-Python, and
+// versions that are not found upstream are left as they are.
+func annotateBuildpackToml(path, id string, releases []PipenvRelease) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	requiresPython := map[string]string{}
+	for _, release := range releases {
+		requiresPython[release.Version().Original()] = release.RequiresPython
+	}
+
+	lines := strings.Split(string(content), "\n")
+
+	var annotated []string
+	for start := 0; start < len(lines); {
+		end := start + 1
+		if strings.TrimSpace(lines[start]) == "[[metadata.dependencies]]" {
+			for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "[") {
+				end++
+			}
+
+			annotated = append(annotated, annotateDependency(lines[start:end], id, requiresPython)...)
+		} else {
+			annotated = append(annotated, lines[start])
+		}
+		start = end
+	}
+
+	err = os.WriteFile(path, []byte(strings.Join(annotated, "\n")), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// annotateDependency returns the lines of a [[metadata.dependencies]] table
+// with its requires-python key set, if it is an id dependency of a known
+// version. The key is placed in alphabetical order, as the action orders the
+// other keys, and is indented like them.
+func annotateDependency(lines []string, id string, requiresPython map[string]string) []string {
+	var dependencyID, version, indent string
+	for _, line := range lines[1:] {
+		key, value, ok := parseKeyValue(line)
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "id":
+			dependencyID = value
+		case "version":
+			version = value
+		}
+		indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	}
+
+	if dependencyID != id {
+		return lines
+	}
+
+	specifier, ok := requiresPython[version]
+	if !ok {
+		fmt.Printf("Skipping requires-python of version %s: not found upstream\n", version)
+		return lines
+	}
+
+	// The version key sorts after requires-python, so the key is always
+	// inserted.
+	annotated := []string{lines[0]}
+	inserted := specifier == ""
+	for _, line := range lines[1:] {
+		key, _, ok := parseKeyValue(line)
+		if ok && key == requiresPythonKey {
+			continue
+		}
+
+		if ok && !inserted && key > requiresPythonKey {
+			annotated = append(annotated, fmt.Sprintf("%s%s = %s", indent, requiresPythonKey, strconv.Quote(specifier)))
+			inserted = true
+		}
+		annotated = append(annotated, line)
+	}
+
+	return annotated
+}
+
+// parseKeyValue returns the key of a "key = value" line, and its value when it
+// is a string.
+func parseKeyValue(line string) (string, string, bool) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok {
+		return "", "", false
+	}
+
+	value = strings.TrimSpace(value)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+
+	return strings.TrimSpace(key), value, true
+}
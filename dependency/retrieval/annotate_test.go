@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testAnnotate(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path     string
+		releases []PipenvRelease
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "buildpack.toml")
+
+		// As written by the update-from-metadata action, which drops
+		// requires-python, with one key left over from an earlier run.
+		Expect(os.WriteFile(path, []byte(`api = "0.7"
+
+[metadata]
+  include-files = ["buildpack.toml"]
+
+  [[metadata.dependencies]]
+    checksum = "sha256:some-checksum"
+    id = "pipenv"
+    purl = "pkg:pypi/pipenv@2026.7.0"
+    source = "https://example.com/pipenv-2026.7.0.tar.gz"
+    version = "2026.7.0"
+
+  [[metadata.dependencies]]
+    checksum = "sha256:other-checksum"
+    id = "pipenv"
+    purl = "pkg:pypi/pipenv@2026.7.1"
+    requires-python = ">=3.8"
+    source = "https://example.com/pipenv-2026.7.1.tar.gz"
+    version = "2026.7.1"
+
+  [[metadata.dependencies]]
+    id = "pipenv"
+    version = "2026.6.0"
+
+  [[metadata.dependencies]]
+    id = "other"
+    version = "2026.7.0"
+
+  [[metadata.dependency-constraints]]
+    constraint = "*"
+    id = "pipenv"
+    patches = 2
+`), 0644)).To(Succeed())
+
+		releases = []PipenvRelease{
+			{version: pep440.MustParse("2026.7.0"), RequiresPython: ">=3.9"},
+			{version: pep440.MustParse("2026.7.1"), RequiresPython: ">=3.10"},
+		}
+	})
+
+	it("sets the requires-python of each dependency from its release", func() {
+		Expect(annotateBuildpackToml(path, "pipenv", releases)).To(Succeed())
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal(`api = "0.7"
+
+[metadata]
+  include-files = ["buildpack.toml"]
+
+  [[metadata.dependencies]]
+    checksum = "sha256:some-checksum"
+    id = "pipenv"
+    purl = "pkg:pypi/pipenv@2026.7.0"
+    requires-python = ">=3.9"
+    source = "https://example.com/pipenv-2026.7.0.tar.gz"
+    version = "2026.7.0"
+
+  [[metadata.dependencies]]
+    checksum = "sha256:other-checksum"
+    id = "pipenv"
+    purl = "pkg:pypi/pipenv@2026.7.1"
+    requires-python = ">=3.10"
+    source = "https://example.com/pipenv-2026.7.1.tar.gz"
+    version = "2026.7.1"
+
+  [[metadata.dependencies]]
+    id = "pipenv"
+    version = "2026.6.0"
+
+  [[metadata.dependencies]]
+    id = "other"
+    version = "2026.7.0"
+
+  [[metadata.dependency-constraints]]
+    constraint = "*"
+    id = "pipenv"
+    patches = 2
+`))
+	})
+
+	context("when a release does not declare Requires-Python", func() {
+		it.Before(func() {
+			releases[1].RequiresPython = ""
+		})
+
+		it("removes the key", func() {
+			Expect(annotateBuildpackToml(path, "pipenv", releases)).To(Succeed())
+
+			content, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).NotTo(ContainSubstring(">=3.8"))
+			Expect(string(content)).To(ContainSubstring(`requires-python = ">=3.9"`))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when buildpack.toml does not exist", func() {
+			it("returns an error", func() {
+				err := annotateBuildpackToml(filepath.Join(t.TempDir(), "buildpack.toml"), "pipenv", releases)
+				Expect(err).To(MatchError(ContainSubstring("failed to read")))
+			})
+		})
+	})
+}
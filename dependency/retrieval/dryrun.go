@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/paketo-buildpacks/packit/v2/cargo"
+)
+
+// DryRunSummary describes how buildpack.toml would change if the retrieved
+// metadata were applied.
+type DryRunSummary struct {
+	Added   []string          `json:"added"`
+	Pruned  []PrunedVersion   `json:"pruned"`
+	Changed []DependencyDelta `json:"changed"`
+}
+
+// PrunedVersion is an existing version that falls outside the newest
+// "patches" versions of its dependency constraint once the new versions are
+// added.
+type PrunedVersion struct {
+	Version    string `json:"version"`
+	Constraint string `json:"constraint"`
+	Patches    int    `json:"patches"`
+}
+
+// DependencyDelta is a field of an existing dependency whose value differs
+// from the one upstream reports now.
+type DependencyDelta struct {
+	Version string `json:"version"`
+	Field   string `json:"field"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// pruneVersions returns the existing versions of id that no dependency
+// constraint keeps once the added versions are merged in. A constraint keeps
+// its newest "patches" matching versions. Versions that match no constraint
+// are kept, and nothing is pruned when id has no constraints.
+func pruneVersions(id string, config cargo.Config, added []string) ([]PrunedVersion, error) {
+	seen := map[string]bool{}
+	var versions []pep440.Version
+	var existing []string
+
+	parse := func(version string) error {
+		if seen[version] {
+			return nil
+		}
+		seen[version] = true
+
+		v, err := pep440.Parse(version)
+		if err != nil {
+			return fmt.Errorf("failed to parse version %q of %q dependency: %w", version, id, err)
+		}
+		versions = append(versions, v)
+		return nil
+	}
+
+	for _, dependency := range config.Metadata.Dependencies {
+		if dependency.ID != id {
+			continue
+		}
+		if !seen[dependency.Version] {
+			existing = append(existing, dependency.Version)
+		}
+		if err := parse(dependency.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, version := range added {
+		if err := parse(version); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].GreaterThan(versions[j])
+	})
+
+	kept := map[string]bool{}
+	outside := map[string]PrunedVersion{}
+	matched := map[string]bool{}
+	for _, c := range config.Metadata.DependencyConstraints {
+		if c.ID != id {
+			continue
+		}
+
+		specifiers, err := pep440.NewSpecifiers(c.Constraint, pep440.WithPreRelease(true))
+		if err != nil {
+			return nil, fmt.Errorf("invalid dependency constraint %q for %q: %w", c.Constraint, id, err)
+		}
+
+		count := 0
+		for _, version := range versions {
+			if !specifiers.Check(version) {
+				continue
+			}
+
+			matched[version.Original()] = true
+			count++
+			if count <= c.Patches {
+				kept[version.Original()] = true
+			} else if _, ok := outside[version.Original()]; !ok {
+				outside[version.Original()] = PrunedVersion{Version: version.Original(), Constraint: c.Constraint, Patches: c.Patches}
+			}
+		}
+	}
+
+	var pruned []PrunedVersion
+	for _, version := range existing {
+		if matched[version] && !kept[version] {
+			pruned = append(pruned, outside[version])
+		}
+	}
+
+	return pruned, nil
+}
+
+// diffDependency compares the licenses, checksums and package URL of an
+// existing dependency with freshly generated metadata for the same version.
+func diffDependency(existing cargo.ConfigMetadataDependency, current PipenvDependency) []DependencyDelta {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"licenses", formatLicenses(existing.Licenses), formatLicenses(current.Licenses)},
+		{"checksum", existing.Checksum, current.Checksum},
+		{"source-checksum", existing.SourceChecksum, current.SourceChecksum},
+		{"purl", existing.PURL, current.PURL},
+	}
+
+	var deltas []DependencyDelta
+	for _, field := range fields {
+		if field.old != field.new {
+			deltas = append(deltas, DependencyDelta{
+				Version: existing.Version,
+				Field:   field.name,
+				Old:     field.old,
+				New:     field.new,
+			})
+		}
+	}
+
+	return deltas
+}
+
+func formatLicenses(licenses []interface{}) string {
+	var names []string
+	for _, license := range licenses {
+		names = append(names, fmt.Sprint(license))
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// WriteText writes the summary for humans, one line per change.
+func (s DryRunSummary) WriteText(w io.Writer, id string) error {
+	var b strings.Builder
+
+	if len(s.Added) == 0 && len(s.Pruned) == 0 && len(s.Changed) == 0 {
+		fmt.Fprintf(&b, "Dry run: no changes to %s dependencies\n", id)
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	fmt.Fprintf(&b, "Dry run: planned changes to %s dependencies\n", id)
+
+	if len(s.Added) > 0 {
+		fmt.Fprintf(&b, "  Added (%d):\n", len(s.Added))
+		for _, version := range s.Added {
+			fmt.Fprintf(&b, "    + %s\n", version)
+		}
+	}
+
+	if len(s.Pruned) > 0 {
+		fmt.Fprintf(&b, "  Pruned (%d):\n", len(s.Pruned))
+		for _, pruned := range s.Pruned {
+			fmt.Fprintf(&b, "    - %s (constraint %q keeps %d patches)\n", pruned.Version, pruned.Constraint, pruned.Patches)
+		}
+	}
+
+	if len(s.Changed) > 0 {
+		fmt.Fprintf(&b, "  Changed (%d):\n", len(s.Changed))
+		for _, delta := range s.Changed {
+			fmt.Fprintf(&b, "    ~ %s %s: %q -> %q\n", delta.Version, delta.Field, delta.Old, delta.New)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
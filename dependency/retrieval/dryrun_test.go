@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/cargo"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDryRun(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		config cargo.Config
+	)
+
+	it.Before(func() {
+		config = cargo.Config{
+			Metadata: cargo.ConfigMetadata{
+				Dependencies: []cargo.ConfigMetadataDependency{
+					{ID: "pipenv", Version: "2026.7.0", Stacks: []string{"*"}},
+					{ID: "pipenv", Version: "2026.7.0", Stacks: []string{"io.buildpacks.stacks.jammy"}},
+					{ID: "pipenv", Version: "2026.7.1", Stacks: []string{"*"}},
+					{ID: "other", Version: "2020.1.0"},
+				},
+				DependencyConstraints: []cargo.ConfigMetadataDependencyConstraint{
+					{ID: "pipenv", Constraint: "*", Patches: 2},
+					{ID: "other", Constraint: "*", Patches: 1},
+				},
+			},
+		}
+	})
+
+	context("pruneVersions", func() {
+		it("returns the existing versions outside the newest patches of their constraint", func() {
+			pruned, err := pruneVersions("pipenv", config, []string{"2026.8.0"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pruned).To(Equal([]PrunedVersion{
+				{Version: "2026.7.0", Constraint: "*", Patches: 2},
+			}))
+		})
+
+		context("when nothing is added", func() {
+			it("prunes nothing", func() {
+				pruned, err := pruneVersions("pipenv", config, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pruned).To(BeEmpty())
+			})
+		})
+
+		context("when another constraint keeps a version", func() {
+			it.Before(func() {
+				config.Metadata.DependencyConstraints = append(config.Metadata.DependencyConstraints,
+					cargo.ConfigMetadataDependencyConstraint{ID: "pipenv", Constraint: "==2026.7.*", Patches: 2})
+			})
+
+			it("keeps it", func() {
+				pruned, err := pruneVersions("pipenv", config, []string{"2026.8.0", "2026.8.1"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pruned).To(BeEmpty())
+			})
+		})
+
+		context("when there are no constraints", func() {
+			it.Before(func() {
+				config.Metadata.DependencyConstraints = nil
+			})
+
+			it("prunes nothing", func() {
+				pruned, err := pruneVersions("pipenv", config, []string{"2026.8.0", "2026.8.1"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pruned).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when a constraint is not a PEP 440 specifier", func() {
+				it.Before(func() {
+					config.Metadata.DependencyConstraints[0].Constraint = "^2026"
+				})
+
+				it("returns an error", func() {
+					_, err := pruneVersions("pipenv", config, nil)
+					Expect(err).To(MatchError(ContainSubstring(`invalid dependency constraint "^2026" for "pipenv"`)))
+				})
+			})
+
+			context("when an added version is not a PEP 440 version", func() {
+				it("returns an error", func() {
+					_, err := pruneVersions("pipenv", config, []string{"latest"})
+					Expect(err).To(MatchError(ContainSubstring(`failed to parse version "latest" of "pipenv" dependency`)))
+				})
+			})
+		})
+	})
+
+	context("diffDependency", func() {
+		it("returns the licenses, checksums and purl that changed", func() {
+			existing := cargo.ConfigMetadataDependency{
+				Version:        "2026.7.1",
+				Licenses:       []interface{}{"MIT"},
+				Checksum:       "sha256:old",
+				SourceChecksum: "sha256:old",
+				PURL:           "pkg:generic/pipenv@2026.7.1",
+				CPE:            "cpe:2.3:a:python-pipenv:pipenv:2026.7.1:*:*:*:*:python:*:*",
+			}
+
+			current := PipenvDependency{
+				ConfigMetadataDependency: cargo.ConfigMetadataDependency{
+					Version:        "2026.7.1",
+					Licenses:       []interface{}{"MIT-0", "MIT"},
+					Checksum:       "sha256:old",
+					SourceChecksum: "sha256:new",
+					PURL:           "pkg:pypi/pipenv@2026.7.1",
+					CPE:            "cpe:2.3:a:pypa:pipenv:2026.7.1:*:*:*:*:python:*:*",
+				},
+			}
+
+			Expect(diffDependency(existing, current)).To(Equal([]DependencyDelta{
+				{Version: "2026.7.1", Field: "licenses", Old: "MIT", New: "MIT, MIT-0"},
+				{Version: "2026.7.1", Field: "source-checksum", Old: "sha256:old", New: "sha256:new"},
+				{Version: "2026.7.1", Field: "purl", Old: "pkg:generic/pipenv@2026.7.1", New: "pkg:pypi/pipenv@2026.7.1"},
+			}))
+		})
+
+		context("when the licenses only differ in order", func() {
+			it("returns no changes", func() {
+				existing := cargo.ConfigMetadataDependency{Version: "2026.7.1", Licenses: []interface{}{"MIT", "MIT-0"}}
+				current := PipenvDependency{ConfigMetadataDependency: cargo.ConfigMetadataDependency{Version: "2026.7.1", Licenses: []interface{}{"MIT-0", "MIT"}}}
+
+				Expect(diffDependency(existing, current)).To(BeEmpty())
+			})
+		})
+	})
+
+	context("WriteText", func() {
+		it("writes a line per change", func() {
+			summary := DryRunSummary{
+				Added:   []string{"2026.8.0"},
+				Pruned:  []PrunedVersion{{Version: "2026.7.0", Constraint: "*", Patches: 2}},
+				Changed: []DependencyDelta{{Version: "2026.7.1", Field: "licenses", Old: "MIT", New: "MIT, MIT-0"}},
+			}
+
+			buffer := bytes.NewBuffer(nil)
+			Expect(summary.WriteText(buffer, "pipenv")).To(Succeed())
+			Expect(buffer.String()).To(Equal(`Dry run: planned changes to pipenv dependencies
+  Added (1):
+    + 2026.8.0
+  Pruned (1):
+    - 2026.7.0 (constraint "*" keeps 2 patches)
+  Changed (1):
+    ~ 2026.7.1 licenses: "MIT" -> "MIT, MIT-0"
+`))
+		})
+
+		context("when there are no changes", func() {
+			it("says so", func() {
+				buffer := bytes.NewBuffer(nil)
+				Expect(DryRunSummary{}.WriteText(buffer, "pipenv")).To(Succeed())
+				Expect(buffer.String()).To(Equal("Dry run: no changes to pipenv dependencies\n"))
+			})
+		})
+	})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/paketo-buildpacks/libdependency/upstream"
+)
+
+const (
+	// IndexAPIJSON is PyPI's legacy JSON API, served at <index>/<project>/json.
+	IndexAPIJSON = "json"
+
+	// IndexAPISimple is the PEP 691 JSON-based Simple API, served at
+	// <index>/<project>/.
+	IndexAPISimple = "simple"
+
+	simpleAPIContentType = "application/vnd.pypi.simple.v1+json"
+)
+
+// defaultIndexURLs are the PyPI URLs of each index API.
+var defaultIndexURLs = map[string]string{
+	IndexAPIJSON:   "https://pypi.org/pypi",
+	IndexAPISimple: "https://pypi.org/simple",
+}
+
+// PyPiFile is a distribution file of a release, as listed by either API.
+type PyPiFile struct {
+	Version        string
+	Sdist          bool
+	URL            string
+	UploadTime     string
+	Digests        map[string]string
+	RequiresPython string
+	Yanked         bool
+	YankedReason   string
+
+	// ProvenanceURL is where the PEP 740 provenance of the file is served.
+	ProvenanceURL string
+}
+
+// fetchIndexFiles lists the files of the project on the index at indexURL
+// using the given API. An empty indexURL selects PyPI.
+func fetchIndexFiles(api, indexURL, project string) ([]PyPiFile, error) {
+	if indexURL == "" {
+		indexURL = defaultIndexURLs[api]
+	}
+	indexURL = strings.TrimSuffix(indexURL, "/")
+
+	switch api {
+	case IndexAPIJSON:
+		return fetchJSONAPIFiles(fmt.Sprintf("%s/%s/json", indexURL, project), integrityURL(indexURL, project))
+	case IndexAPISimple:
+		return fetchSimpleAPIFiles(fmt.Sprintf("%s/%s/", indexURL, project), project)
+	default:
+		return nil, fmt.Errorf("unknown index API %q: must be %q or %q", api, IndexAPIJSON, IndexAPISimple)
+	}
+}
+
+type PyPiProductMetadataRaw struct {
+	Releases map[string][]struct {
+		PackageType    string            `json:"packagetype"`
+		URL            string            `json:"url"`
+		UploadTime     string            `json:"upload_time_iso_8601"`
+		Digests        map[string]string `json:"digests"`
+		Yanked         bool              `json:"yanked"`
+		YankedReason   string            `json:"yanked_reason"`
+		RequiresPython string            `json:"requires_python"`
+	} `json:"releases"`
+}
+
+// integrityURL returns the base URL of PyPI's Integrity API for the project,
+// which is served next to the JSON API rather than listed in it.
+func integrityURL(indexURL, project string) string {
+	return fmt.Sprintf("%s/integrity/%s", strings.TrimSuffix(indexURL, "/pypi"), project)
+}
+
+func fetchJSONAPIFiles(projectURL, integrityURL string) ([]PyPiFile, error) {
+	var metadata PyPiProductMetadataRaw
+	err := upstream.GetAndUnmarshal(projectURL, &metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []PyPiFile
+	for version, releases := range metadata.Releases {
+		for _, release := range releases {
+			files = append(files, PyPiFile{
+				Version:        version,
+				Sdist:          release.PackageType == "sdist",
+				URL:            release.URL,
+				UploadTime:     release.UploadTime,
+				Digests:        release.Digests,
+				RequiresPython: release.RequiresPython,
+				Yanked:         release.Yanked,
+				YankedReason:   release.YankedReason,
+				ProvenanceURL:  fmt.Sprintf("%s/%s/%s/provenance", integrityURL, version, path.Base(release.URL)),
+			})
+		}
+	}
+
+	return files, nil
+}
+
+// SimpleProjectRaw is a project page of the PEP 691 Simple API. The
+// upload-time field was added by PEP 700 and the provenance field by PEP 740.
+type SimpleProjectRaw struct {
+	Files []struct {
+		Filename       string            `json:"filename"`
+		URL            string            `json:"url"`
+		Hashes         map[string]string `json:"hashes"`
+		RequiresPython string            `json:"requires-python"`
+		Yanked         json.RawMessage   `json:"yanked"`
+		UploadTime     string            `json:"upload-time"`
+		Provenance     string            `json:"provenance"`
+	} `json:"files"`
+}
+
+// sdistFilename matches source distribution filenames, whose project name is
+// normalized with underscores since PEP 625.
+var sdistFilename = regexp.MustCompile(`^(.+)-([^-]+)\.(tar\.gz|zip)$`)
+
+func fetchSimpleAPIFiles(projectURL, project string) ([]PyPiFile, error) {
+	request, err := http.NewRequest(http.MethodGet, projectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", simpleAPIContentType)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("could not get project metadata: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query url %s with: status code %d", projectURL, response.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType != simpleAPIContentType {
+		return nil, fmt.Errorf("index at %s does not support the JSON Simple API: got content type %q", projectURL, response.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+
+	var page SimpleProjectRaw
+	err = json.Unmarshal(body, &page)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal response: %w", err)
+	}
+
+	var files []PyPiFile
+	for _, file := range page.Files {
+		matches := sdistFilename.FindStringSubmatch(file.Filename)
+		if matches == nil || normalizeProjectName(matches[1]) != normalizeProjectName(project) {
+			continue
+		}
+
+		fileURL, err := response.Request.URL.Parse(file.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url %q for %s: %w", file.URL, file.Filename, err)
+		}
+
+		yanked, reason, err := parseYanked(file.Yanked)
+		if err != nil {
+			return nil, fmt.Errorf("invalid yanked value for %s: %w", file.Filename, err)
+		}
+
+		var provenanceURL string
+		if file.Provenance != "" {
+			u, err := response.Request.URL.Parse(file.Provenance)
+			if err != nil {
+				return nil, fmt.Errorf("invalid provenance url %q for %s: %w", file.Provenance, file.Filename, err)
+			}
+			provenanceURL = u.String()
+		}
+
+		files = append(files, PyPiFile{
+			Version:        matches[2],
+			Sdist:          true,
+			URL:            stripFragment(fileURL),
+			UploadTime:     file.UploadTime,
+			Digests:        file.Hashes,
+			RequiresPython: file.RequiresPython,
+			Yanked:         yanked,
+			YankedReason:   reason,
+			ProvenanceURL:  provenanceURL,
+		})
+	}
+
+	return files, nil
+}
+
+// parseYanked reads the Simple API yanked value, which is either a boolean or
+// the reason the file was yanked.
+func parseYanked(value json.RawMessage) (bool, string, error) {
+	if len(value) == 0 {
+		return false, "", nil
+	}
+
+	var yanked bool
+	if err := json.Unmarshal(value, &yanked); err == nil {
+		return yanked, "", nil
+	}
+
+	var reason string
+	err := json.Unmarshal(value, &reason)
+	if err != nil {
+		return false, "", err
+	}
+
+	return true, reason, nil
+}
+
+func stripFragment(u *url.URL) string {
+	u.Fragment = ""
+	return u.String()
+}
+
+var projectNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizeProjectName normalizes a project name as described in PEP 503.
+func normalizeProjectName(name string) string {
+	return projectNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testIndex(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		server *httptest.Server
+		accept string
+	)
+
+	it.Before(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			accept = req.Header.Get("Accept")
+
+			switch req.URL.Path {
+			case "/pypi/pipenv/json":
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{
+					"releases": {
+						"2026.7.1": [
+							{
+								"packagetype": "bdist_wheel",
+								"url": "https://files.example.com/pipenv-2026.7.1-py3-none-any.whl",
+								"upload_time_iso_8601": "2026-07-02T10:00:00.000000Z",
+								"digests": {"sha256": "some-wheel-sha"}
+							},
+							{
+								"packagetype": "sdist",
+								"url": "https://files.example.com/pipenv-2026.7.1.tar.gz",
+								"upload_time_iso_8601": "2026-07-02T10:00:00.123456Z",
+								"digests": {"sha256": "some-sdist-sha"},
+								"requires_python": ">=3.9"
+							}
+						],
+						"2026.7.0": [
+							{
+								"packagetype": "sdist",
+								"url": "https://files.example.com/pipenv-2026.7.0.tar.gz",
+								"upload_time_iso_8601": "2026-07-01T10:00:00Z",
+								"digests": {"sha256": "yanked-sha"},
+								"yanked": true,
+								"yanked_reason": "broken release"
+							}
+						],
+						"not-a-version": [
+							{
+								"packagetype": "sdist",
+								"url": "https://files.example.com/pipenv-not-a-version.tar.gz",
+								"upload_time_iso_8601": "2026-07-01T10:00:00Z",
+								"digests": {"sha256": "other-sha"}
+							}
+						]
+					}
+				}`)
+
+			case "/simple/pipenv/":
+				w.Header().Set("Content-Type", "application/vnd.pypi.simple.v1+json")
+				fmt.Fprint(w, `{
+					"meta": {"api-version": "1.1"},
+					"name": "pipenv",
+					"files": [
+						{
+							"filename": "pipenv-2026.7.1-py3-none-any.whl",
+							"url": "../../files/pipenv-2026.7.1-py3-none-any.whl",
+							"hashes": {"sha256": "some-wheel-sha"}
+						},
+						{
+							"filename": "pipenv-2026.7.1.tar.gz",
+							"url": "../../files/pipenv-2026.7.1.tar.gz#sha256=some-sdist-sha",
+							"hashes": {"sha256": "some-sdist-sha"},
+							"requires-python": ">=3.9",
+							"yanked": false,
+							"upload-time": "2026-07-02T10:00:00.123456Z",
+							"provenance": "../../integrity/pipenv-2026.7.1.tar.gz/provenance"
+						},
+						{
+							"filename": "pipenv-2026.7.0.tar.gz",
+							"url": "https://files.example.com/pipenv-2026.7.0.tar.gz",
+							"hashes": {"sha256": "yanked-sha"},
+							"yanked": "broken release"
+						}
+					]
+				}`)
+
+			case "/legacy/pipenv/":
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprint(w, `<html></html>`)
+
+			default:
+				http.NotFound(w, req)
+			}
+		}))
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	context("getAllVersionsFromIndex", func() {
+		context("with the JSON API", func() {
+			it("returns the source distributions that were not yanked", func() {
+				versions, err := getAllVersionsFromIndex(IndexAPIJSON, server.URL+"/pypi/", false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(versions).To(HaveLen(1))
+
+				release := versions[0]
+				Expect(release.Version().String()).To(Equal("2026.7.1"))
+				Expect(release.SourceURL).To(Equal("https://files.example.com/pipenv-2026.7.1.tar.gz"))
+				Expect(release.SourceSHA256).To(Equal("some-sdist-sha"))
+				Expect(release.ProvenanceURL).To(Equal(server.URL + "/integrity/pipenv/2026.7.1/pipenv-2026.7.1.tar.gz/provenance"))
+				Expect(release.RequiresPython).To(Equal(">=3.9"))
+				Expect(release.UploadTime).To(Equal(time.Date(2026, 7, 2, 10, 0, 0, 123456000, time.UTC)))
+			})
+
+			it("includes yanked releases when asked to", func() {
+				versions, err := getAllVersionsFromIndex(IndexAPIJSON, server.URL+"/pypi", true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(versions).To(HaveLen(2))
+			})
+		})
+
+		context("with the Simple API", func() {
+			it("returns the source distributions that were not yanked", func() {
+				versions, err := getAllVersionsFromIndex(IndexAPISimple, server.URL+"/simple", false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(accept).To(Equal("application/vnd.pypi.simple.v1+json"))
+				Expect(versions).To(HaveLen(1))
+
+				release := versions[0]
+				Expect(release.Version().String()).To(Equal("2026.7.1"))
+				Expect(release.SourceURL).To(Equal(server.URL + "/files/pipenv-2026.7.1.tar.gz"))
+				Expect(release.SourceSHA256).To(Equal("some-sdist-sha"))
+				Expect(release.ProvenanceURL).To(Equal(server.URL + "/integrity/pipenv-2026.7.1.tar.gz/provenance"))
+				Expect(release.RequiresPython).To(Equal(">=3.9"))
+				Expect(release.UploadTime).To(Equal(time.Date(2026, 7, 2, 10, 0, 0, 123456000, time.UTC)))
+			})
+
+			it("includes yanked releases when asked to", func() {
+				versions, err := getAllVersionsFromIndex(IndexAPISimple, server.URL+"/simple/", true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(versions).To(HaveLen(2))
+
+				release := versions[1]
+				Expect(release.Version().String()).To(Equal("2026.7.0"))
+				Expect(release.UploadTime).To(BeZero())
+				Expect(release.ProvenanceURL).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the index API is unknown", func() {
+				it("returns an error", func() {
+					_, err := getAllVersionsFromIndex("xmlrpc", server.URL, false)
+					Expect(err).To(MatchError(`could not retrieve new versions from upstream: unknown index API "xmlrpc": must be "json" or "simple"`))
+				})
+			})
+
+			context("when the project does not exist", func() {
+				it("returns an error", func() {
+					_, err := getAllVersionsFromIndex(IndexAPISimple, server.URL+"/missing", false)
+					Expect(err).To(MatchError(ContainSubstring("status code 404")))
+				})
+			})
+
+			context("when the index only serves the HTML Simple API", func() {
+				it("returns an error", func() {
+					_, err := getAllVersionsFromIndex(IndexAPISimple, server.URL+"/legacy", false)
+					Expect(err).To(MatchError(ContainSubstring(`does not support the JSON Simple API: got content type "text/html"`)))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitRetrieval(t *testing.T) {
+	suite := spec.New("retrieval", spec.Report(report.Terminal{}))
+	suite("Annotate", testAnnotate)
+	suite("DryRun", testDryRun)
+	suite("Index", testIndex)
+	suite("Provenance", testProvenance)
+	suite("PURL", testPURL)
+	suite("Versions", testVersions)
+	suite.Run(t)
+}
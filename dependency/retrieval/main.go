@@ -1,103 +1,310 @@
 package main
 
 import (
-	"errors"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
 	"time"
 
-	"github.com/Masterminds/semver/v3"
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/paketo-buildpacks/libdependency/buildpack_config"
 	"github.com/paketo-buildpacks/libdependency/retrieve"
 	"github.com/paketo-buildpacks/libdependency/upstream"
-	"github.com/paketo-buildpacks/libdependency/versionology"
 	"github.com/paketo-buildpacks/packit/v2/cargo"
 )
 
-type PyPiProductMetadataRaw struct {
-	Releases map[string][]struct {
-		PackageType string            `json:"packagetype"`
-		URL         string            `json:"url"`
-		UploadTime  string            `json:"upload_time_iso_8601"`
-		Digests     map[string]string `json:"digests"`
-	} `json:"releases"`
-}
+var (
+	includeYanked   = flag.Bool("include-yanked", false, "include releases that were yanked from the index")
+	allowPrerelease = flag.Bool("allow-prerelease", false, "include pre-releases and development releases")
+	indexAPI        = flag.String("index-api", IndexAPIJSON, "index API to query: \"json\" (PyPI's JSON API) or \"simple\" (the PEP 691 JSON Simple API)")
+	indexURL        = flag.String("index-url", "", "base URL of the index API (defaults to https://pypi.org/pypi or https://pypi.org/simple)")
+
+	trustedRootPath     = flag.String("trusted-root", "", "path to a Sigstore trusted_root.json used to verify attestations (defaults to the embedded trusted root of the Sigstore public-good instance)")
+	publisherRepository = flag.String("publisher-repository", "pypa/pipenv", "GitHub repository expected to publish pipenv")
+	publisherWorkflow   = flag.String("publisher-workflow", "", "GitHub Actions workflow expected to publish pipenv (defaults to any workflow of the repository)")
+	requireAttestation  = flag.Bool("require-attestation", true, "fail when a release has no attestations")
+	skipAttestations    = flag.Bool("skip-attestations", false, "only check the digests of releases, without verifying their attestations")
+
+	annotate = flag.Bool("annotate", false, "set the requires-python of each pipenv dependency in buildpack.toml from the index instead of writing metadata")
+
+	dryRun     = flag.Bool("dry-run", false, "print the planned changes to buildpack.toml instead of writing metadata")
+	dryRunJSON = flag.String("dry-run-json", "", "path to also write the dry run summary to as JSON")
+)
 
 type PipenvRelease struct {
-	version      *semver.Version
-	SourceURL    string
-	UploadTime   time.Time
-	SourceSHA256 string
+	version        pep440.Version
+	SourceURL      string
+	UploadTime     time.Time
+	SourceSHA256   string
+	SourceDigests  map[string]string
+	ProvenanceURL  string
+	RequiresPython string
+}
+
+// PipenvDependency is the dependency metadata written for a release. It adds
+// the release's Requires-Python specifier and the verification result of its
+// source distribution, which cargo.ConfigMetadataDependency has no fields for.
+// The update-from-metadata action drops both when it writes buildpack.toml:
+// --annotate then sets requires-python there, and the provenance is kept in
+// the metadata.json artifact of the workflow run.
+type PipenvDependency struct {
+	cargo.ConfigMetadataDependency
+	RequiresPython string      `json:"requires-python,omitempty"`
+	Provenance     *Provenance `json:"provenance,omitempty"`
 }
 
-func (release PipenvRelease) Version() *semver.Version {
+func (release PipenvRelease) Version() pep440.Version {
 	return release.version
 }
 
-func getAllVersions() (versionology.VersionFetcherArray, error) {
-	var pipenvMetadata PyPiProductMetadataRaw
-	err := upstream.GetAndUnmarshal("https://pypi.org/pypi/pipenv/json", &pipenvMetadata)
+func getAllVersions() ([]PipenvRelease, error) {
+	return getAllVersionsFromIndex(*indexAPI, *indexURL, *includeYanked)
+}
+
+// getAllVersionsFromIndex returns a release for each source distribution of
+// pipenv on the index whose version is a valid PEP 440 version.
+func getAllVersionsFromIndex(api, indexURL string, includeYanked bool) ([]PipenvRelease, error) {
+	files, err := fetchIndexFiles(api, indexURL, "pipenv")
 	if err != nil {
 		return nil, fmt.Errorf("could not retrieve new versions from upstream: %w", err)
 	}
 
-	var allVersions versionology.VersionFetcherArray
+	var allVersions []PipenvRelease
 
-	for version, releasesForVersion := range pipenvMetadata.Releases {
-		for _, release := range releasesForVersion {
-			if release.PackageType != "sdist" {
-				continue
-			}
+	for _, file := range files {
+		if !file.Sdist {
+			continue
+		}
 
-			fmt.Printf("Parsing semver version %s\n", version)
+		if file.Yanked && !includeYanked {
+			fmt.Printf("Skipping yanked version %s: %s\n", file.Version, file.YankedReason)
+			continue
+		}
 
-			newVersion, err := semver.NewVersion(version)
-			if err != nil {
-				continue
-			}
+		newVersion, err := pep440.Parse(file.Version)
+		if err != nil {
+			fmt.Printf("Skipping version %s: not a PEP 440 version\n", file.Version)
+			continue
+		}
 
-			uploadTime, err := time.Parse(time.RFC3339, release.UploadTime)
+		// The Simple API only reports upload times since PEP 700.
+		var uploadTime time.Time
+		if file.UploadTime != "" {
+			uploadTime, err = time.Parse(time.RFC3339, file.UploadTime)
 			if err != nil {
-				return nil, fmt.Errorf("could not parse upload time '%s' as date for version %s: %w", release.UploadTime, version, err)
+				return nil, fmt.Errorf("could not parse upload time '%s' as date for version %s: %w", file.UploadTime, file.Version, err)
 			}
-
-			allVersions = append(allVersions, PipenvRelease{
-				version:      newVersion,
-				SourceSHA256: release.Digests["sha256"],
-				SourceURL:    release.URL,
-				UploadTime:   uploadTime,
-			})
 		}
+
+		allVersions = append(allVersions, PipenvRelease{
+			version:        newVersion,
+			SourceSHA256:   file.Digests["sha256"],
+			SourceDigests:  file.Digests,
+			SourceURL:      file.URL,
+			ProvenanceURL:  file.ProvenanceURL,
+			UploadTime:     uploadTime,
+			RequiresPython: file.RequiresPython,
+		})
 	}
 
 	return allVersions, nil
 }
 
-func generateMetadata(versionFetcher versionology.VersionFetcher) ([]versionology.Dependency, error) {
-	version := versionFetcher.Version().String()
-	pipenvRelease, ok := versionFetcher.(PipenvRelease)
-	if !ok {
-		return nil, errors.New("expected a PipenvRelease")
+func generateMetadata(pipenvRelease PipenvRelease, verifier ReleaseVerifier) ([]PipenvDependency, error) {
+	version := pipenvRelease.Version().String()
+
+	provenance, err := verifier.Verify(pipenvRelease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify source distribution: %w", err)
 	}
 
+	cpe, err := generateCPE(version)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := fmt.Sprintf("sha256:%s", pipenvRelease.SourceSHA256)
+
 	configMetadataDependency := cargo.ConfigMetadataDependency{
-		CPE:            fmt.Sprintf("cpe:2.3:a:python-pipenv:pipenv:%s:*:*:*:*:python:*:*", version),
-		Checksum:       fmt.Sprintf("sha256:%s", pipenvRelease.SourceSHA256),
+		CPE:            cpe,
+		Checksum:       checksum,
 		ID:             "pipenv",
 		Licenses:       retrieve.LookupLicenses(pipenvRelease.SourceURL, upstream.DefaultDecompress),
 		Name:           "Pipenv",
-		PURL:           retrieve.GeneratePURL("pipenv", version, pipenvRelease.SourceSHA256, pipenvRelease.SourceURL),
+		PURL:           generatePURL("pipenv", version, checksum, pipenvRelease.SourceURL),
 		Source:         pipenvRelease.SourceURL,
-		SourceChecksum: fmt.Sprintf("sha256:%s", pipenvRelease.SourceSHA256),
+		SourceChecksum: checksum,
 		Stacks:         []string{"*"},
 		URI:            pipenvRelease.SourceURL,
 		Version:        version,
 	}
 
-	return []versionology.Dependency{{
+	return []PipenvDependency{{
 		ConfigMetadataDependency: configMetadataDependency,
-		SemverVersion:            versionFetcher.Version(),
+		RequiresPython:           pipenvRelease.RequiresPython,
+		Provenance:               &provenance,
 	}}, nil
 }
 
+// main follows retrieve.NewMetadata, but orders versions with PEP 440 rather
+// than semver, verifies each new release and writes PipenvDependency entries
+// so that the metadata carries the results.
 func main() {
-	retrieve.NewMetadata("pipenv", getAllVersions, generateMetadata)
+	buildpackTomlPath, output := retrieve.FetchArgs()
+	if buildpackTomlPath == "" || (output == "" && !*dryRun && !*annotate) {
+		fmt.Fprintln(os.Stderr, "--buildpack-toml-path and --output are required")
+		os.Exit(1)
+	}
+
+	if *annotate {
+		// Yanked releases may still be in buildpack.toml.
+		allVersions, err := getAllVersionsFromIndex(*indexAPI, *indexURL, true)
+		if err != nil {
+			panic(err)
+		}
+
+		err = annotateBuildpackToml(buildpackTomlPath, "pipenv", allVersions)
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("Annotated %s\n", buildpackTomlPath)
+		return
+	}
+
+	config, err := buildpack_config.ParseBuildpackToml(buildpackTomlPath)
+	if err != nil {
+		panic(err)
+	}
+
+	verifier := ReleaseVerifier{
+		Publisher: Publisher{
+			Kind:       githubPublisherKind,
+			Repository: *publisherRepository,
+			Workflow:   *publisherWorkflow,
+		},
+		RequireAttestation: *requireAttestation,
+		SkipAttestations:   *skipAttestations,
+	}
+
+	verifier.TrustedRoot, err = LoadTrustedRoot(*trustedRootPath)
+	if err != nil {
+		panic(err)
+	}
+
+	allVersions, err := getAllVersions()
+	if err != nil {
+		panic(err)
+	}
+
+	newVersions, err := getNewVersions("pipenv", config, allVersions, *allowPrerelease)
+	if err != nil {
+		panic(err)
+	}
+
+	dependencies := []PipenvDependency{}
+	for _, version := range newVersions {
+		metadata, err := generateMetadata(version, verifier)
+		if err != nil {
+			panic(fmt.Errorf("unable to generate metadata for %s: %w", version.Version(), err))
+		}
+		dependencies = append(dependencies, metadata...)
+	}
+
+	if *dryRun {
+		err = printDryRun("pipenv", config, allVersions, dependencies, verifier)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	content, err := json.Marshal(dependencies)
+	if err != nil {
+		panic(fmt.Errorf("unable to marshal metadata json: %w", err))
+	}
+
+	err = os.WriteFile(output, content, os.ModePerm)
+	if err != nil {
+		panic(fmt.Errorf("cannot write to %s: %w", output, err))
+	}
+
+	fmt.Printf("Wrote metadata to %s\n", output)
+}
+
+// printDryRun prints the summary of the changes that applying dependencies
+// would make to buildpack.toml, and writes it as JSON when --dry-run-json is
+// set. The metadata of the existing versions that are kept is regenerated to
+// find changes to their licenses, checksums and package URLs.
+func printDryRun(id string, config cargo.Config, allVersions []PipenvRelease, dependencies []PipenvDependency, verifier ReleaseVerifier) error {
+	summary := DryRunSummary{
+		Added:   []string{},
+		Pruned:  []PrunedVersion{},
+		Changed: []DependencyDelta{},
+	}
+
+	for _, dependency := range dependencies {
+		summary.Added = append(summary.Added, dependency.Version)
+	}
+
+	pruned, err := pruneVersions(id, config, summary.Added)
+	if err != nil {
+		return err
+	}
+	summary.Pruned = append(summary.Pruned, pruned...)
+
+	isPruned := map[string]bool{}
+	for _, p := range pruned {
+		isPruned[p.Version] = true
+	}
+
+	releases := map[string]PipenvRelease{}
+	for _, release := range allVersions {
+		releases[release.Version().Original()] = release
+	}
+
+	current := map[string]PipenvDependency{}
+	for _, existing := range config.Metadata.Dependencies {
+		if existing.ID != id || isPruned[existing.Version] {
+			continue
+		}
+
+		release, ok := releases[existing.Version]
+		if !ok {
+			fmt.Printf("Skipping comparison of version %s: not found upstream\n", existing.Version)
+			continue
+		}
+
+		if _, ok := current[existing.Version]; !ok {
+			metadata, err := generateMetadata(release, verifier)
+			if err != nil {
+				return fmt.Errorf("unable to generate metadata for %s: %w", existing.Version, err)
+			}
+			current[existing.Version] = metadata[0]
+		}
+
+		summary.Changed = append(summary.Changed, diffDependency(existing, current[existing.Version])...)
+	}
+
+	err = summary.WriteText(os.Stdout, id)
+	if err != nil {
+		return err
+	}
+
+	if *dryRunJSON != "" {
+		content, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to marshal dry run summary: %w", err)
+		}
+
+		err = os.WriteFile(*dryRunJSON, content, 0644)
+		if err != nil {
+			return fmt.Errorf("cannot write to %s: %w", *dryRunJSON, err)
+		}
+
+		fmt.Printf("Wrote dry run summary to %s\n", *dryRunJSON)
+	}
+
+	return nil
 }
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+	"golang.org/x/crypto/blake2b"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// AttestationVerified means a PEP 740 attestation from the expected
+	// publisher was verified for the source distribution.
+	AttestationVerified = "verified"
+
+	// AttestationMissing means the index serves no provenance for the source
+	// distribution, and attestations were not required.
+	AttestationMissing = "missing"
+
+	// AttestationSkipped means attestations were not checked because
+	// verification was explicitly skipped.
+	AttestationSkipped = "skipped"
+
+	inTotoPayloadType    = "application/vnd.in-toto+json"
+	inTotoStatementType  = "https://in-toto.io/Statement/v1"
+	pypiPublishPredicate = "https://docs.pypi.org/attestations/publish/v1"
+	githubActionsIssuer  = "https://token.actions.githubusercontent.com"
+	githubPublisherKind  = "GitHub"
+)
+
+// publicGoodTrustedRoot is a snapshot of the trusted root of the Sigstore
+// public-good instance, which signs PyPI's attestations. It must be refreshed
+// from the Sigstore TUF repository when Sigstore rotates its keys.
+//
+//go:embed trusted_root.json
+var publicGoodTrustedRoot []byte
+
+// Provenance records how the source distribution of a release was verified.
+type Provenance struct {
+	Digests     map[string]string `json:"digests"`
+	Attestation string            `json:"attestation"`
+	Publisher   *Publisher        `json:"publisher,omitempty"`
+	LogIndex    int64             `json:"log-index,omitempty"`
+}
+
+// Publisher is a Trusted Publisher identity, as listed in a PEP 740
+// attestation bundle.
+type Publisher struct {
+	Kind        string `json:"kind"`
+	Repository  string `json:"repository"`
+	Workflow    string `json:"workflow"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// ReleaseVerifier downloads source distributions, recomputes their digests and
+// verifies their PEP 740 attestations against the expected publisher.
+type ReleaseVerifier struct {
+	// TrustedRoot holds the Sigstore certificate authorities and transparency
+	// logs.
+	TrustedRoot root.TrustedMaterial
+
+	// Publisher is the expected publisher. An empty workflow accepts any
+	// workflow of the repository.
+	Publisher Publisher
+
+	// RequireAttestation fails verification of releases without provenance.
+	RequireAttestation bool
+
+	// SkipAttestations only checks the digests.
+	SkipAttestations bool
+}
+
+// LoadTrustedRoot reads a Sigstore trusted_root.json, as distributed by the
+// Sigstore TUF repository. An empty path loads the embedded trusted root of
+// the Sigstore public-good instance.
+func LoadTrustedRoot(path string) (root.TrustedMaterial, error) {
+	if path == "" {
+		trustedRoot, err := root.NewTrustedRootFromJSON(publicGoodTrustedRoot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse embedded trusted root: %w", err)
+		}
+		return trustedRoot, nil
+	}
+
+	trustedRoot, err := root.NewTrustedRootFromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trusted root %s: %w", path, err)
+	}
+
+	return trustedRoot, nil
+}
+
+// Verify checks the source distribution of the release and returns the
+// verification result. It returns an error when a digest does not match, or
+// when an attestation cannot be verified.
+func (v ReleaseVerifier) Verify(release PipenvRelease) (Provenance, error) {
+	if release.SourceSHA256 == "" {
+		return Provenance{}, fmt.Errorf("index reported no sha256 digest for %s", release.SourceURL)
+	}
+
+	digests, err := downloadDigests(release.SourceURL)
+	if err != nil {
+		return Provenance{}, err
+	}
+
+	for algorithm, expected := range release.SourceDigests {
+		if actual, ok := digests[algorithm]; ok && !strings.EqualFold(actual, expected) {
+			return Provenance{}, fmt.Errorf("%s digest mismatch for %s: index reported %s, downloaded %s", algorithm, release.SourceURL, expected, actual)
+		}
+	}
+
+	provenance := Provenance{Digests: digests}
+
+	if v.SkipAttestations {
+		fmt.Printf("WARNING: not verifying attestations of %s, verification was skipped\n", release.SourceURL)
+		provenance.Attestation = AttestationSkipped
+		return provenance, nil
+	}
+
+	if v.TrustedRoot == nil {
+		return Provenance{}, errors.New("no trusted root to verify attestations with")
+	}
+
+	bundles, err := fetchProvenance(release.ProvenanceURL)
+	if err != nil {
+		return Provenance{}, err
+	}
+
+	if len(bundles) == 0 {
+		if v.RequireAttestation {
+			return Provenance{}, fmt.Errorf("no attestations found for %s", release.SourceURL)
+		}
+
+		fmt.Printf("WARNING: no attestations found for %s\n", release.SourceURL)
+		provenance.Attestation = AttestationMissing
+		return provenance, nil
+	}
+
+	filename := path.Base(release.SourceURL)
+	for _, bundle := range bundles {
+		if !v.Publisher.matches(bundle.Publisher) {
+			return Provenance{}, fmt.Errorf("attestation for %s was published by %s, expected %s", filename, bundle.Publisher, v.Publisher)
+		}
+
+		for _, attestation := range bundle.Attestations {
+			statement, logIndex, err := verifyAttestation(v.TrustedRoot, attestation, v.Publisher)
+			if err != nil {
+				return Provenance{}, fmt.Errorf("failed to verify attestation for %s: %w", filename, err)
+			}
+
+			err = verifyStatement(statement, filename, digests["sha256"])
+			if err != nil {
+				return Provenance{}, fmt.Errorf("failed to verify attestation for %s: %w", filename, err)
+			}
+
+			publisher := bundle.Publisher
+			provenance.Publisher = &publisher
+			provenance.LogIndex = logIndex
+		}
+	}
+
+	if provenance.Publisher == nil {
+		return Provenance{}, fmt.Errorf("provenance for %s contains no attestations", filename)
+	}
+
+	fmt.Printf("Verified attestation of %s published by %s\n", filename, provenance.Publisher)
+	provenance.Attestation = AttestationVerified
+
+	return provenance, nil
+}
+
+func (p Publisher) String() string {
+	return fmt.Sprintf("%s %s (workflow %q)", p.Kind, p.Repository, p.Workflow)
+}
+
+// matches reports whether the publisher listed in an attestation bundle is
+// the expected publisher.
+func (p Publisher) matches(actual Publisher) bool {
+	return actual.Kind == githubPublisherKind &&
+		strings.EqualFold(actual.Repository, p.Repository) &&
+		(p.Workflow == "" || actual.Workflow == p.Workflow)
+}
+
+// downloadDigests downloads the file at url and returns its digests, named as
+// in PyPI's JSON API.
+func downloadDigests(url string) (map[string]string, error) {
+	response, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not download %s: %w", url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s with: status code %d", url, response.StatusCode)
+	}
+
+	blake2b256, err := blake2b.New256(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := map[string]hash.Hash{
+		"sha256":      sha256.New(),
+		"md5":         md5.New(),
+		"blake2b_256": blake2b256,
+	}
+
+	var writers []io.Writer
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+
+	_, err = io.Copy(io.MultiWriter(writers...), response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not download %s: %w", url, err)
+	}
+
+	digests := map[string]string{}
+	for algorithm, h := range hashes {
+		digests[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return digests, nil
+}
+
+// ProvenanceRaw is a PEP 740 provenance object.
+type ProvenanceRaw struct {
+	AttestationBundles []AttestationBundleRaw `json:"attestation_bundles"`
+}
+
+type AttestationBundleRaw struct {
+	Publisher    Publisher        `json:"publisher"`
+	Attestations []AttestationRaw `json:"attestations"`
+}
+
+// AttestationRaw is a PEP 740 attestation. Its transparency log entries are
+// Sigstore TransparencyLogEntry messages in the protobuf JSON encoding.
+type AttestationRaw struct {
+	VerificationMaterial struct {
+		Certificate         string            `json:"certificate"`
+		TransparencyEntries []json.RawMessage `json:"transparency_entries"`
+	} `json:"verification_material"`
+	Envelope struct {
+		Statement string `json:"statement"`
+		Signature string `json:"signature"`
+	} `json:"envelope"`
+}
+
+// fetchProvenance returns the attestation bundles served at url, or none when
+// the index has no provenance for the file.
+func fetchProvenance(url string) ([]AttestationBundleRaw, error) {
+	if url == "" {
+		return nil, nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/vnd.pypi.integrity.v1+json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("could not get provenance: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query url %s with: status code %d", url, response.StatusCode)
+	}
+
+	var provenance ProvenanceRaw
+	err = json.NewDecoder(response.Body).Decode(&provenance)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal provenance: %w", err)
+	}
+
+	return provenance.AttestationBundles, nil
+}
+
+// verifyAttestation verifies that the attestation was signed by a certificate
+// issued to the publisher's GitHub workflow and logged in a transparency log
+// of the trusted root. It returns the signed in-toto statement and the index
+// of its log entry.
+//
+// The artifact is not part of the policy: sigstore-go accepts a statement
+// with any subject of the given digest, and verifyStatement checks the file
+// name as well.
+func verifyAttestation(trustedRoot root.TrustedMaterial, attestation AttestationRaw, publisher Publisher) ([]byte, int64, error) {
+	entity, err := attestationBundle(attestation)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	verifier, err := verify.NewVerifier(trustedRoot, verify.WithTransparencyLog(1), verify.WithObserverTimestamps(1))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	identity, err := verify.NewShortCertificateIdentity(githubActionsIssuer, "", "", publisherIdentity(publisher))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, err = verifier.Verify(entity, verify.NewPolicy(verify.WithoutArtifactUnsafe(), verify.WithCertificateIdentity(identity)))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := entity.TlogEntries()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	envelope, err := entity.Envelope()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	statement, err := envelope.RawEnvelope().DecodeB64Payload()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return statement, entries[0].LogIndex(), nil
+}
+
+// publisherIdentity returns the pattern of the certificate identities of the
+// publisher's workflows: the URI of the workflow file at some ref.
+func publisherIdentity(publisher Publisher) string {
+	workflow := "[^/@]+"
+	if publisher.Workflow != "" {
+		workflow = regexp.QuoteMeta(publisher.Workflow)
+	}
+
+	return fmt.Sprintf("^(?i:https://github\\.com/%s/\\.github/workflows/)%s@", regexp.QuoteMeta(publisher.Repository), workflow)
+}
+
+// attestationBundle converts a PEP 740 attestation into the Sigstore bundle it
+// was made from. Attestations whose log entry has an inclusion proof are
+// version 0.3 bundles; older ones only carry the signed entry timestamp of a
+// version 0.1 bundle.
+func attestationBundle(attestation AttestationRaw) (*bundle.Bundle, error) {
+	certificate, err := base64.StdEncoding.DecodeString(attestation.VerificationMaterial.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate encoding: %w", err)
+	}
+
+	statement, err := base64.StdEncoding.DecodeString(attestation.Envelope.Statement)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statement encoding: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attestation.Envelope.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	if len(attestation.VerificationMaterial.TransparencyEntries) == 0 {
+		return nil, errors.New("attestation has no transparency log entry")
+	}
+
+	version := "0.3"
+	var entries []*protorekor.TransparencyLogEntry
+	for _, raw := range attestation.VerificationMaterial.TransparencyEntries {
+		var entry protorekor.TransparencyLogEntry
+		err = protojson.Unmarshal(raw, &entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transparency log entry: %w", err)
+		}
+
+		if entry.InclusionProof == nil {
+			version = "0.1"
+		}
+		entries = append(entries, &entry)
+	}
+
+	mediaType, err := bundle.MediaTypeString(version)
+	if err != nil {
+		return nil, err
+	}
+
+	material := &protobundle.VerificationMaterial{TlogEntries: entries}
+	if version == "0.1" {
+		material.Content = &protobundle.VerificationMaterial_X509CertificateChain{
+			X509CertificateChain: &protocommon.X509CertificateChain{
+				Certificates: []*protocommon.X509Certificate{{RawBytes: certificate}},
+			},
+		}
+	} else {
+		material.Content = &protobundle.VerificationMaterial_Certificate{
+			Certificate: &protocommon.X509Certificate{RawBytes: certificate},
+		}
+	}
+
+	b, err := bundle.NewBundle(&protobundle.Bundle{
+		MediaType:            mediaType,
+		VerificationMaterial: material,
+		Content: &protobundle.Bundle_DsseEnvelope{
+			DsseEnvelope: &protodsse.Envelope{
+				Payload:     statement,
+				PayloadType: inTotoPayloadType,
+				Signatures:  []*protodsse.Signature{{Sig: signature}},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation: %w", err)
+	}
+
+	return b, nil
+}
+
+// verifyStatement checks that the in-toto statement is a PyPI publish
+// attestation for the file.
+func verifyStatement(content []byte, filename, sha256Digest string) error {
+	var statement struct {
+		Type    string `json:"_type"`
+		Subject []struct {
+			Name   string            `json:"name"`
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+		PredicateType string `json:"predicateType"`
+	}
+
+	err := json.Unmarshal(content, &statement)
+	if err != nil {
+		return fmt.Errorf("invalid statement: %w", err)
+	}
+
+	if statement.Type != inTotoStatementType || statement.PredicateType != pypiPublishPredicate {
+		return fmt.Errorf("statement is not a PyPI publish attestation: got predicate type %q", statement.PredicateType)
+	}
+
+	for _, subject := range statement.Subject {
+		if subject.Name == filename && strings.EqualFold(subject.Digest["sha256"], sha256Digest) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("statement does not attest %s with sha256 %s", filename, sha256Digest)
+}
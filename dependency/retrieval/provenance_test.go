@@ -0,0 +1,451 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/sclevine/spec"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	. "github.com/onsi/gomega"
+)
+
+// attestation returns PEP 740 provenance holding an attestation of the
+// statement by the virtual Sigstore instance, with a log entry that has both
+// a signed entry timestamp and an inclusion proof, as PyPI's do.
+func attestation(t *testing.T, sigstore *ca.VirtualSigstore, publisher Publisher, identity, issuer string, statement []byte) ProvenanceRaw {
+	t.Helper()
+	Expect := NewWithT(t).Expect
+
+	entity, err := sigstore.AttestAtTime(identity, issuer, statement, time.Now().Add(5*time.Minute), true)
+	Expect(err).NotTo(HaveOccurred())
+
+	content, err := entity.VerificationContent()
+	Expect(err).NotTo(HaveOccurred())
+
+	signature, err := entity.SignatureContent()
+	Expect(err).NotTo(HaveOccurred())
+	envelope := signature.EnvelopeContent().RawEnvelope()
+
+	entries, err := entity.TlogEntries()
+	Expect(err).NotTo(HaveOccurred())
+
+	entry := entries[0].TransparencyLogEntry()
+	entry.KindVersion = &protorekor.KindVersion{Kind: "intoto", Version: "0.0.2"}
+
+	set, err := sigstore.RekorSignPayload(tlog.RekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(entry.CanonicalizedBody),
+		IntegratedTime: entry.IntegratedTime,
+		LogIndex:       entry.LogIndex,
+		LogID:          hex.EncodeToString(entry.LogId.KeyId),
+	})
+	Expect(err).NotTo(HaveOccurred())
+	entry.InclusionPromise = &protorekor.InclusionPromise{SignedEntryTimestamp: set}
+
+	rawEntry, err := protojson.Marshal(entry)
+	Expect(err).NotTo(HaveOccurred())
+
+	var raw AttestationRaw
+	raw.VerificationMaterial.Certificate = base64.StdEncoding.EncodeToString(content.Certificate().Raw)
+	raw.VerificationMaterial.TransparencyEntries = []json.RawMessage{rawEntry}
+	raw.Envelope.Statement = envelope.Payload
+	raw.Envelope.Signature = envelope.Signatures[0].Sig
+
+	return ProvenanceRaw{AttestationBundles: []AttestationBundleRaw{{Publisher: publisher, Attestations: []AttestationRaw{raw}}}}
+}
+
+// modifyEntry rewrites the transparency log entry of the first attestation.
+func modifyEntry(t *testing.T, provenance *ProvenanceRaw, modify func(*protorekor.TransparencyLogEntry)) {
+	t.Helper()
+	Expect := NewWithT(t).Expect
+
+	entries := provenance.AttestationBundles[0].Attestations[0].VerificationMaterial.TransparencyEntries
+
+	var entry protorekor.TransparencyLogEntry
+	Expect(protojson.Unmarshal(entries[0], &entry)).To(Succeed())
+
+	modify(&entry)
+
+	var err error
+	entries[0], err = protojson.Marshal(&entry)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func publishStatement(subject, digest string) []byte {
+	return []byte(fmt.Sprintf(`{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":%q,"digest":{"sha256":%q}}],"predicateType":"https://docs.pypi.org/attestations/publish/v1","predicate":null}`, subject, digest))
+}
+
+func testProvenance(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		sigstore   *ca.VirtualSigstore
+		server     *httptest.Server
+		sdist      []byte
+		provenance *ProvenanceRaw
+		publisher  Publisher
+		identity   string
+		statement  []byte
+		release    PipenvRelease
+		verifier   ReleaseVerifier
+	)
+
+	it.Before(func() {
+		var err error
+		sigstore, err = ca.NewVirtualSigstore()
+		Expect(err).NotTo(HaveOccurred())
+
+		sdist = []byte("pipenv source distribution")
+		digest := sha256.Sum256(sdist)
+
+		publisher = Publisher{Kind: "GitHub", Repository: "pypa/pipenv", Workflow: "pypi_upload.yml"}
+		identity = "https://github.com/pypa/pipenv/.github/workflows/pypi_upload.yml@refs/tags/v2026.7.1"
+		statement = publishStatement("pipenv-2026.7.1.tar.gz", hex.EncodeToString(digest[:]))
+		provenance = nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/files/pipenv-2026.7.1.tar.gz":
+				_, _ = w.Write(sdist)
+
+			case "/integrity/pipenv/2026.7.1/pipenv-2026.7.1.tar.gz/provenance":
+				if provenance == nil {
+					http.NotFound(w, req)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(provenance)
+
+			default:
+				http.NotFound(w, req)
+			}
+		}))
+
+		release = PipenvRelease{
+			version:       pep440.MustParse("2026.7.1"),
+			SourceURL:     server.URL + "/files/pipenv-2026.7.1.tar.gz",
+			SourceSHA256:  hex.EncodeToString(digest[:]),
+			SourceDigests: map[string]string{"sha256": hex.EncodeToString(digest[:])},
+			ProvenanceURL: server.URL + "/integrity/pipenv/2026.7.1/pipenv-2026.7.1.tar.gz/provenance",
+		}
+
+		verifier = ReleaseVerifier{
+			TrustedRoot:        sigstore,
+			Publisher:          Publisher{Kind: "GitHub", Repository: "pypa/pipenv", Workflow: "pypi_upload.yml"},
+			RequireAttestation: true,
+		}
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	context("Verify", func() {
+		it.Before(func() {
+			p := attestation(t, sigstore, publisher, identity, githubActionsIssuer, statement)
+			provenance = &p
+		})
+
+		it("records the digests and the verified publisher", func() {
+			result, err := verifier.Verify(release)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Attestation).To(Equal(AttestationVerified))
+			Expect(result.Publisher).To(Equal(&publisher))
+			Expect(result.LogIndex).To(Equal(int64(0)))
+			Expect(result.Digests).To(HaveKeyWithValue("sha256", release.SourceSHA256))
+			Expect(result.Digests).To(HaveKey("md5"))
+			Expect(result.Digests).To(HaveKey("blake2b_256"))
+		})
+
+		context("when any workflow of the repository is accepted", func() {
+			it.Before(func() {
+				verifier.Publisher.Workflow = ""
+			})
+
+			it("verifies the attestation", func() {
+				result, err := verifier.Verify(release)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Attestation).To(Equal(AttestationVerified))
+			})
+		})
+
+		context("when attestations are skipped", func() {
+			it.Before(func() {
+				verifier.SkipAttestations = true
+				verifier.TrustedRoot = nil
+			})
+
+			it("only checks the digests", func() {
+				result, err := verifier.Verify(release)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Attestation).To(Equal(AttestationSkipped))
+				Expect(result.Publisher).To(BeNil())
+			})
+		})
+
+		context("when the release has no provenance", func() {
+			it.Before(func() {
+				provenance = nil
+			})
+
+			it("returns an error", func() {
+				_, err := verifier.Verify(release)
+				Expect(err).To(MatchError(fmt.Sprintf("no attestations found for %s", release.SourceURL)))
+			})
+
+			context("when attestations are not required", func() {
+				it.Before(func() {
+					verifier.RequireAttestation = false
+				})
+
+				it("records that the attestation is missing", func() {
+					result, err := verifier.Verify(release)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Attestation).To(Equal(AttestationMissing))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when there is no trusted root", func() {
+				it.Before(func() {
+					verifier.TrustedRoot = nil
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError("no trusted root to verify attestations with"))
+				})
+			})
+
+			context("when the downloaded digest does not match the index", func() {
+				it.Before(func() {
+					sdist = []byte("tampered")
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("sha256 digest mismatch")))
+				})
+			})
+
+			context("when the source distribution cannot be downloaded", func() {
+				it.Before(func() {
+					release.SourceURL = server.URL + "/files/missing.tar.gz"
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("status code 404")))
+				})
+			})
+
+			context("when the bundle was published by someone else", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, Publisher{Kind: "GitHub", Repository: "attacker/pipenv", Workflow: "pypi_upload.yml"}, identity, githubActionsIssuer, statement)
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring(`was published by GitHub attacker/pipenv (workflow "pypi_upload.yml")`)))
+				})
+			})
+
+			context("when the certificate identity is another repository", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, "https://github.com/attacker/pipenv/.github/workflows/pypi_upload.yml@refs/heads/main", githubActionsIssuer, statement)
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("no matching CertificateIdentity found")))
+				})
+			})
+
+			context("when the certificate identity is another workflow", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, "https://github.com/pypa/pipenv/.github/workflows/ci.yml@refs/heads/main", githubActionsIssuer, statement)
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("no matching CertificateIdentity found")))
+				})
+			})
+
+			context("when the certificate was issued by another OIDC provider", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, identity, "https://accounts.example.com", statement)
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("no matching CertificateIdentity found")))
+				})
+			})
+
+			context("when the statement attests another file", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, identity, githubActionsIssuer, publishStatement("pipenv-2026.7.1.tar.gz", hex.EncodeToString(make([]byte, 32))))
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("statement does not attest pipenv-2026.7.1.tar.gz")))
+				})
+			})
+
+			context("when the statement was tampered with", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, identity, githubActionsIssuer, statement)
+					p.AttestationBundles[0].Attestations[0].Envelope.Statement = base64.StdEncoding.EncodeToString([]byte(`{}`))
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("could not verify envelope")))
+				})
+			})
+
+			context("when the signed entry timestamp is invalid", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, identity, githubActionsIssuer, statement)
+					modifyEntry(t, &p, func(entry *protorekor.TransparencyLogEntry) {
+						entry.IntegratedTime++
+					})
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("failed to verify log inclusion")))
+				})
+			})
+
+			context("when the inclusion proof is invalid", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, identity, githubActionsIssuer, statement)
+					modifyEntry(t, &p, func(entry *protorekor.TransparencyLogEntry) {
+						entry.InclusionProof.RootHash = make([]byte, 32)
+					})
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("failed to verify log inclusion: calculated root")))
+				})
+			})
+
+			context("when the transparency log is not trusted", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, identity, githubActionsIssuer, statement)
+					modifyEntry(t, &p, func(entry *protorekor.TransparencyLogEntry) {
+						entry.LogId = &protocommon.LogId{KeyId: make([]byte, 32)}
+					})
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("failed to verify log inclusion")))
+				})
+			})
+
+			context("when the attestation was made by another Sigstore instance", func() {
+				it.Before(func() {
+					other, err := ca.NewVirtualSigstore()
+					Expect(err).NotTo(HaveOccurred())
+
+					p := attestation(t, other, publisher, identity, githubActionsIssuer, statement)
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("failed to verify log inclusion")))
+				})
+			})
+
+			context("when the attestation has no transparency log entry", func() {
+				it.Before(func() {
+					p := attestation(t, sigstore, publisher, identity, githubActionsIssuer, statement)
+					p.AttestationBundles[0].Attestations[0].VerificationMaterial.TransparencyEntries = nil
+					provenance = &p
+				})
+
+				it("returns an error", func() {
+					_, err := verifier.Verify(release)
+					Expect(err).To(MatchError(ContainSubstring("attestation has no transparency log entry")))
+				})
+			})
+		})
+	})
+
+	// The fixture is a real attestation logged in the Sigstore public-good
+	// Rekor instance: the npm provenance of sigstore 1.3.0, from the
+	// sigstore-go examples, re-encoded as a PEP 740 attestation.
+	context("with a real attestation", func() {
+		var real ProvenanceRaw
+
+		it.Before(func() {
+			content, err := os.ReadFile(filepath.Join("testdata", "sigstore-js-1.3.0.provenance.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(content, &real)).To(Succeed())
+
+			verifier.TrustedRoot, err = LoadTrustedRoot("")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("verifies it against the embedded trusted root", func() {
+			statement, logIndex, err := verifyAttestation(verifier.TrustedRoot, real.AttestationBundles[0].Attestations[0], real.AttestationBundles[0].Publisher)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logIndex).To(Equal(int64(18300934)))
+			Expect(string(statement)).To(ContainSubstring(`"name":"pkg:npm/sigstore@1.3.0"`))
+		})
+
+		it("rejects it for another publisher", func() {
+			_, _, err := verifyAttestation(verifier.TrustedRoot, real.AttestationBundles[0].Attestations[0], publisher)
+			Expect(err).To(MatchError(ContainSubstring("no matching CertificateIdentity found")))
+		})
+
+		it("rejects it as the provenance of a PyPI release", func() {
+			provenance = &real
+			verifier.Publisher = real.AttestationBundles[0].Publisher
+
+			_, err := verifier.Verify(release)
+			Expect(err).To(MatchError(ContainSubstring(`statement is not a PyPI publish attestation: got predicate type "https://slsa.dev/provenance/v0.2"`)))
+		})
+	})
+
+	context("LoadTrustedRoot", func() {
+		context("failure cases", func() {
+			context("when the trusted root does not exist", func() {
+				it("returns an error", func() {
+					_, err := LoadTrustedRoot(filepath.Join(t.TempDir(), "trusted_root.json"))
+					Expect(err).To(MatchError(ContainSubstring("failed to load trusted root")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// generatePURL returns the pkg:pypi package URL of the source distribution,
+// qualified with its file name and checksum. It replaces retrieve.GeneratePURL,
+// whose pkg:generic URLs are not matched against the PyPI ecosystem.
+func generatePURL(name, version, checksum, sourceURL string) string {
+	purl := fmt.Sprintf("pkg:pypi/%s@%s", normalizeProjectName(name), escapePURLComponent(version))
+
+	var qualifiers []string
+	if checksum != "" {
+		qualifiers = append(qualifiers, fmt.Sprintf("checksum=%s", escapePURLComponent(checksum)))
+	}
+	if u, err := url.Parse(sourceURL); err == nil && sourceURL != "" {
+		qualifiers = append(qualifiers, fmt.Sprintf("file_name=%s", escapePURLComponent(path.Base(u.Path))))
+	}
+
+	if len(qualifiers) > 0 {
+		purl = fmt.Sprintf("%s?%s", purl, strings.Join(qualifiers, "&"))
+	}
+
+	return purl
+}
+
+// escapePURLComponent percent-encodes everything but the characters the
+// package URL specification leaves unencoded.
+func escapePURLComponent(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == ':':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// generateCPE returns the CPE 2.3 formatted string the NVD uses for the
+// version of pipenv, and an error when it is not well formed.
+func generateCPE(version string) (string, error) {
+	var escaped strings.Builder
+	for _, c := range version {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_') {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(c)
+	}
+
+	cpe := fmt.Sprintf("cpe:2.3:a:pypa:pipenv:%s:*:*:*:*:python:*:*", escaped.String())
+
+	return cpe, validateCPE(cpe)
+}
+
+// cpeComponent matches a component of a CPE 2.3 formatted string, as defined
+// by NISTIR 7695: a logical value, or alphanumerics and escaped punctuation
+// with optional leading and trailing wildcards.
+var cpeComponent = regexp.MustCompile(`^(\*|-|(\?*|\*?)([A-Za-z0-9._-]|\\[\\*?!"#$%&'()+,/:;<=>@\[\]^` + "`" + `{|}~])+(\?*|\*?))$`)
+
+// validateCPE checks that cpe is a well-formed CPE 2.3 formatted string.
+func validateCPE(cpe string) error {
+	var components []string
+	var current strings.Builder
+	escaped := false
+	for _, c := range cpe {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ':':
+			components = append(components, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+	components = append(components, current.String())
+
+	if len(components) != 13 || components[0] != "cpe" || components[1] != "2.3" {
+		return fmt.Errorf("invalid CPE %q: must be a CPE 2.3 formatted string with 13 components", cpe)
+	}
+
+	switch components[2] {
+	case "a", "o", "h", "*", "-":
+	default:
+		return fmt.Errorf("invalid CPE %q: part must be one of \"a\", \"o\" or \"h\"", cpe)
+	}
+
+	for _, component := range components[3:] {
+		if !cpeComponent.MatchString(component) {
+			return fmt.Errorf("invalid CPE %q: malformed component %q", cpe, component)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPURL(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("generatePURL", func() {
+		it("returns a pkg:pypi package URL with the file name and checksum", func() {
+			Expect(generatePURL(
+				"Pipenv",
+				"2026.7.1",
+				"sha256:29b9450d52eff3570b28f35d30586cccca68e89a579b92ce4f0b6b59aef30214",
+				"https://files.pythonhosted.org/packages/e8/af/aebabe333f35f71220a860fb1f6de5ccd7942c4029ae09fce7aada5f9644/pipenv-2026.7.1.tar.gz",
+			)).To(Equal("pkg:pypi/pipenv@2026.7.1?checksum=sha256:29b9450d52eff3570b28f35d30586cccca68e89a579b92ce4f0b6b59aef30214&file_name=pipenv-2026.7.1.tar.gz"))
+		})
+
+		it("percent-encodes the version", func() {
+			Expect(generatePURL("pipenv", "1!2026.7.1+local", "", "")).To(Equal("pkg:pypi/pipenv@1%212026.7.1%2Blocal"))
+		})
+	})
+
+	context("generateCPE", func() {
+		it("returns the NVD CPE", func() {
+			cpe, err := generateCPE("2026.7.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cpe).To(Equal("cpe:2.3:a:pypa:pipenv:2026.7.1:*:*:*:*:python:*:*"))
+		})
+
+		it("escapes the version", func() {
+			cpe, err := generateCPE("1!2026.7.1+local")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cpe).To(Equal(`cpe:2.3:a:pypa:pipenv:1\!2026.7.1\+local:*:*:*:*:python:*:*`))
+		})
+	})
+
+	context("validateCPE", func() {
+		it("accepts well-formed CPEs", func() {
+			Expect(validateCPE("cpe:2.3:a:pypa:pipenv:2026.7.1:*:*:*:*:python:*:*")).To(Succeed())
+			Expect(validateCPE(`cpe:2.3:a:pypa:pipenv:2026\:7:*:*:*:*:*:*:-`)).To(Succeed())
+		})
+
+		it("rejects malformed CPEs", func() {
+			Expect(validateCPE("cpe:2.3:a:pypa:pipenv:2026.7.1")).To(MatchError(ContainSubstring("must be a CPE 2.3 formatted string with 13 components")))
+			Expect(validateCPE("cpe:2.3:x:pypa:pipenv:2026.7.1:*:*:*:*:python:*:*")).To(MatchError(ContainSubstring(`part must be one of "a", "o" or "h"`)))
+			Expect(validateCPE("cpe:2.3:a:pypa:pipenv:2026.7.1+local:*:*:*:*:python:*:*")).To(MatchError(ContainSubstring(`malformed component "2026.7.1+local"`)))
+			Expect(validateCPE("cpe:2.3:a::pipenv:2026.7.1:*:*:*:*:python:*:*")).To(MatchError(ContainSubstring(`malformed component ""`)))
+		})
+	})
+}
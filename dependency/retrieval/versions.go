@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/paketo-buildpacks/packit/v2/cargo"
+)
+
+// getNewVersions follows retrieve.GetNewVersionsForId with PEP 440 versions
+// and constraints, which libdependency cannot parse. It returns, oldest
+// first, the upstream releases that satisfy a dependency constraint for id
+// and are newer than every dependency in buildpack.toml that satisfies the
+// same constraint, keeping the newest "patches" releases per constraint.
+// Without constraints, every release newer than the existing dependencies is
+// returned. Pre-releases are left out unless allowPrerelease is true.
+func getNewVersions(id string, config cargo.Config, releases []PipenvRelease, allowPrerelease bool) ([]PipenvRelease, error) {
+	var existing []pep440.Version
+	for _, dependency := range config.Metadata.Dependencies {
+		if dependency.ID != id {
+			continue
+		}
+
+		version, err := pep440.Parse(dependency.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse version %q of %q dependency: %w", dependency.Version, id, err)
+		}
+		existing = append(existing, version)
+	}
+
+	var candidates []PipenvRelease
+	for _, release := range releases {
+		if release.Version().IsPreRelease() && !allowPrerelease {
+			continue
+		}
+		candidates = append(candidates, release)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Version().LessThan(candidates[j].Version())
+	})
+
+	type constraint struct {
+		specifiers *pep440.Specifiers
+		patches    int
+	}
+
+	var constraints []constraint
+	for _, c := range config.Metadata.DependencyConstraints {
+		if c.ID != id {
+			continue
+		}
+
+		specifiers, err := pep440.NewSpecifiers(c.Constraint, pep440.WithPreRelease(allowPrerelease))
+		if err != nil {
+			return nil, fmt.Errorf("invalid dependency constraint %q for %q: %w", c.Constraint, id, err)
+		}
+		constraints = append(constraints, constraint{specifiers: &specifiers, patches: c.Patches})
+	}
+
+	if len(constraints) == 0 {
+		constraints = append(constraints, constraint{patches: len(candidates)})
+	}
+
+	selected := map[string]bool{}
+	for _, c := range constraints {
+		check := func(v pep440.Version) bool {
+			return c.specifiers == nil || c.specifiers.Check(v)
+		}
+
+		var newest *pep440.Version
+		for i, version := range existing {
+			if check(version) && (newest == nil || version.GreaterThan(*newest)) {
+				newest = &existing[i]
+			}
+		}
+
+		var matching []PipenvRelease
+		for _, release := range candidates {
+			if check(release.Version()) && (newest == nil || release.Version().GreaterThan(*newest)) {
+				matching = append(matching, release)
+			}
+		}
+
+		if len(matching) > c.patches {
+			matching = matching[len(matching)-c.patches:]
+		}
+
+		for _, release := range matching {
+			selected[release.Version().String()] = true
+		}
+	}
+
+	var newVersions []PipenvRelease
+	var logged []string
+	for _, release := range candidates {
+		if selected[release.Version().String()] {
+			newVersions = append(newVersions, release)
+			logged = append(logged, release.Version().String())
+		}
+	}
+
+	fmt.Printf("Found %d new versions of %s: [%s]\n", len(newVersions), id, strings.Join(logged, ", "))
+
+	return newVersions, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/paketo-buildpacks/packit/v2/cargo"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVersions(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		config   cargo.Config
+		releases []PipenvRelease
+	)
+
+	versionsOf := func(releases []PipenvRelease) []string {
+		var versions []string
+		for _, release := range releases {
+			versions = append(versions, release.Version().String())
+		}
+		return versions
+	}
+
+	it.Before(func() {
+		config = cargo.Config{
+			Metadata: cargo.ConfigMetadata{
+				Dependencies: []cargo.ConfigMetadataDependency{
+					{ID: "pipenv", Version: "2023.12.1"},
+					{ID: "pipenv", Version: "2024.0.1"},
+					{ID: "other", Version: "2030.0.0"},
+				},
+			},
+		}
+
+		for _, version := range []string{"2024.2.0", "2023.12.2", "2024.0.1.post1", "2024.1.0.1", "2024.0.1", "2025.0.0rc1", "2025.0.0.dev1", "2022.1.1"} {
+			releases = append(releases, PipenvRelease{version: pep440.MustParse(version)})
+		}
+	})
+
+	it.After(func() {
+		releases = nil
+	})
+
+	context("getNewVersions", func() {
+		it("returns the final releases newer than the existing dependencies in PEP 440 order", func() {
+			newVersions, err := getNewVersions("pipenv", config, releases, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versionsOf(newVersions)).To(Equal([]string{"2024.0.1.post1", "2024.1.0.1", "2024.2.0"}))
+		})
+
+		context("when pre-releases are allowed", func() {
+			it("returns them too", func() {
+				newVersions, err := getNewVersions("pipenv", config, releases, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(versionsOf(newVersions)).To(Equal([]string{"2024.0.1.post1", "2024.1.0.1", "2024.2.0", "2025.0.0.dev1", "2025.0.0rc1"}))
+			})
+		})
+
+		context("with dependency constraints", func() {
+			it.Before(func() {
+				config.Metadata.DependencyConstraints = []cargo.ConfigMetadataDependencyConstraint{
+					{ID: "pipenv", Constraint: "==2023.*", Patches: 2},
+					{ID: "pipenv", Constraint: "~=2024.0", Patches: 1},
+					{ID: "other", Constraint: "*", Patches: 5},
+				}
+			})
+
+			it("returns the newest patches per constraint", func() {
+				newVersions, err := getNewVersions("pipenv", config, releases, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(versionsOf(newVersions)).To(Equal([]string{"2023.12.2", "2024.2.0"}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when a constraint is not a PEP 440 specifier", func() {
+				it.Before(func() {
+					config.Metadata.DependencyConstraints = []cargo.ConfigMetadataDependencyConstraint{
+						{ID: "pipenv", Constraint: "^2024", Patches: 1},
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := getNewVersions("pipenv", config, releases, false)
+					Expect(err).To(MatchError(ContainSubstring(`invalid dependency constraint "^2024" for "pipenv"`)))
+				})
+			})
+
+			context("when an existing version is not a PEP 440 version", func() {
+				it.Before(func() {
+					config.Metadata.Dependencies = append(config.Metadata.Dependencies, cargo.ConfigMetadataDependency{ID: "pipenv", Version: "latest"})
+				})
+
+				it("returns an error", func() {
+					_, err := getNewVersions("pipenv", config, releases, false)
+					Expect(err).To(MatchError(ContainSubstring(`failed to parse version "latest" of "pipenv" dependency`)))
+				})
+			})
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
 
 // BuildPlanMetadata is the buildpack specific data included in build plan
@@ -23,16 +24,73 @@ type BuildPlanMetadata struct {
 	Launch bool `toml:"launch"`
 }
 
+// PackagingFilesMetadata is the metadata of the pipenv-packaging-files build
+// plan requirement.
+type PackagingFilesMetadata struct {
+	// PackagingFiles lists the Python packaging files found in the app, such
+	// as Pipfile or poetry.lock.
+	PackagingFiles []string `toml:"packaging-files"`
+}
+
 // Detect will return a packit.DetectFunc that will be invoked during the
 // detect phase of the buildpack lifecycle.
 //
 // This buildpack always passes detection and will contribute a Build Plan that
 // provides pipenv.
 //
+// The Python packaging files in the app are logged and recorded in the
+// metadata of a pipenv-packaging-files entry that this buildpack both
+// provides and requires, leaving the metadata of the requirements read by
+// other buildpacks alone. When Pipenv files coexist with another
+// package manager's lockfile, such as requirements.txt or poetry.lock, a
+// warning is logged, or detection fails when $BP_PIPENV_CONFLICT_CHECK is
+// "fail".
+//
+// The buildpack is configured through its environment variables, or the
+// pyproject.toml or project.toml of the app (see Configuration). A file that
+// cannot be parsed is skipped with a warning, so that detection only fails on
+// an invalid table for the buildpack. The effective configuration is logged at
+// the debug level.
+//
 // If a version is provided via the $BP_PIPENV_VERSION environment variable,
-// that version of pipenv will be a requirement.
-func Detect() packit.DetectFunc {
+// that version of pipenv will be a requirement. A set but empty
+// $BP_PIPENV_VERSION requires pipenv without a version.
+//
+// When the app pins a python version in a pyenv .python-version file or a
+// runtime.txt file, cpython is also required at that version, with the file
+// as the version source. Pins that disagree fail detection.
+//
+// If $BP_PIPENV_BUILD or $BP_PIPENV_LAUNCH is true, the buildpack requires
+// pipenv itself at build or launch time, so that pipenv is available even
+// when no other buildpack requires it. Requiring it at launch also requires
+// cpython at launch.
+//
+// If $BP_PIPENV_INSTALL_APP_DEPENDENCIES or $BP_PIPENV_EXPORT_REQUIREMENTS
+// is true, the buildpack requires pipenv itself and also provides
+// site-packages or requirements respectively, falling back to providing just
+// pipenv when nothing requires them.
+func Detect(logger scribe.Emitter) packit.DetectFunc {
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
+		config, err := LoadConfiguration(context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+		config.Log(logger)
+
+		files, err := inventoryPackagingFiles(context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		err = checkPackagingConflicts(files, config.ConflictCheck, logger)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		var packagingFileNames []string
+		for _, file := range files {
+			packagingFileNames = append(packagingFileNames, file.Name)
+		}
 
 		requirements := []packit.BuildPlanRequirement{
 			{
@@ -49,23 +107,113 @@ func Detect() packit.DetectFunc {
 			},
 		}
 
-		pipEnvVersion, ok := os.LookupEnv("BP_PIPENV_VERSION")
-		if ok {
+		// Every plan provides the packaging files entry along with pipenv.
+		pipenvProvisions := []packit.BuildPlanProvision{{Name: Pipenv}}
+		if len(packagingFileNames) > 0 {
+			requirements = append(requirements, packit.BuildPlanRequirement{
+				Name: PackagingFiles,
+				Metadata: PackagingFilesMetadata{
+					PackagingFiles: packagingFileNames,
+				},
+			})
+			pipenvProvisions = append(pipenvProvisions, packit.BuildPlanProvision{Name: PackagingFiles})
+		}
+
+		build, launch := config.Build, config.Launch
+
+		// Running pipenv in the image needs python there too.
+		if launch {
+			requirements[1].Metadata = BuildPlanMetadata{Build: true, Launch: true}
+		}
+
+		pins, err := readPythonVersionPins(context.WorkingDir, logger)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+		for _, pin := range pins {
 			requirements = append(requirements, packit.BuildPlanRequirement{
-				Name: Pipenv,
+				Name: CPython,
 				Metadata: BuildPlanMetadata{
-					Version:       pipEnvVersion,
-					VersionSource: "BP_PIPENV_VERSION",
+					Version:       pin.Constraint,
+					VersionSource: pin.Source,
+					Build:         true,
+					Launch:        launch,
+				},
+			})
+		}
+
+		// A set but empty $BP_PIPENV_VERSION still requires pipenv, at the
+		// version configured in a file or any version, as it always has.
+		_, versionSet := os.LookupEnv("BP_PIPENV_VERSION")
+		ok := config.Version != "" || versionSet
+		if ok || build || launch {
+			metadata := BuildPlanMetadata{
+				Build:  build,
+				Launch: launch,
+			}
+			if ok {
+				metadata.Version = config.Version
+				metadata.VersionSource = config.Source("BP_PIPENV_VERSION")
+				if metadata.VersionSource == ConfigurationDefault {
+					metadata.VersionSource = "BP_PIPENV_VERSION"
+				}
+			}
+
+			requirements = append(requirements, packit.BuildPlanRequirement{
+				Name:     Pipenv,
+				Metadata: metadata,
+			})
+		}
+
+		var optional []packit.BuildPlanProvision
+
+		if config.InstallAppDependencies {
+			optional = append(optional, packit.BuildPlanProvision{Name: SitePackages})
+		}
+
+		if config.ExportRequirements {
+			optional = append(optional, packit.BuildPlanProvision{Name: Requirements})
+		}
+
+		if len(optional) == 0 {
+			return packit.DetectResult{
+				Plan: packit.BuildPlan{
+					Provides: pipenvProvisions,
+					Requires: requirements,
 				},
+			}, nil
+		}
+
+		// Pipenv is needed to produce the optional provisions, whether or not
+		// any other buildpack requires it.
+		if !ok && !build && !launch {
+			requirements = append(requirements, packit.BuildPlanRequirement{
+				Name:     Pipenv,
+				Metadata: BuildPlanMetadata{},
 			})
 		}
 
+		// Every provision of a plan must be required by some buildpack, so
+		// fall back to plans providing fewer of the optional provisions.
+		var alternatives []packit.BuildPlan
+		if len(optional) > 1 {
+			for _, provision := range optional {
+				alternatives = append(alternatives, packit.BuildPlan{
+					Provides: append(append([]packit.BuildPlanProvision{}, pipenvProvisions...), provision),
+					Requires: requirements,
+				})
+			}
+		}
+		alternatives = append(alternatives, packit.BuildPlan{
+			Provides: pipenvProvisions,
+			Requires: requirements,
+		})
+
 		return packit.DetectResult{
 			Plan: packit.BuildPlan{
-				Provides: []packit.BuildPlanProvision{
-					{Name: Pipenv},
-				},
+				Provides: append(append([]packit.BuildPlanProvision{}, pipenvProvisions...), optional...),
 				Requires: requirements,
+				Or:       alternatives,
 			},
 		}, nil
 	}
@@ -1,11 +1,15 @@
 package pipenv_test
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/BurntSushi/toml"
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 	"github.com/paketo-buildpacks/pipenv"
 	"github.com/sclevine/spec"
 
@@ -17,6 +21,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		Expect = NewWithT(t).Expect
 
 		workingDir string
+		buffer     *bytes.Buffer
 		detect     packit.DetectFunc
 	)
 
@@ -26,7 +31,8 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		err := os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte{}, 0644)
 		Expect(err).NotTo(HaveOccurred())
 
-		detect = pipenv.Detect()
+		buffer = bytes.NewBuffer(nil)
+		detect = pipenv.Detect(scribe.NewEmitter(buffer))
 	})
 
 	it("returns a plan that provides pipenv", func() {
@@ -38,6 +44,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			Plan: packit.BuildPlan{
 				Provides: []packit.BuildPlanProvision{
 					{Name: "pipenv"},
+					{Name: "pipenv-packaging-files"},
 				},
 				Requires: []packit.BuildPlanRequirement{
 					{
@@ -54,6 +61,12 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 							Launch: false,
 						},
 					},
+					{
+						Name: pipenv.PackagingFiles,
+						Metadata: pipenv.PackagingFilesMetadata{
+							PackagingFiles: []string{"Pipfile"},
+						},
+					},
 				},
 			},
 		}))
@@ -73,6 +86,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 				Plan: packit.BuildPlan{
 					Provides: []packit.BuildPlanProvision{
 						{Name: "pipenv"},
+						{Name: "pipenv-packaging-files"},
 					},
 					Requires: []packit.BuildPlanRequirement{
 						{
@@ -89,6 +103,12 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 								Launch: false,
 							},
 						},
+						{
+							Name: pipenv.PackagingFiles,
+							Metadata: pipenv.PackagingFilesMetadata{
+								PackagingFiles: []string{"Pipfile"},
+							},
+						},
 						{
 							Name: "pipenv",
 							Metadata: pipenv.BuildPlanMetadata{
@@ -102,4 +122,597 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when BP_PIPENV_VERSION is set but empty", func() {
+		it.Before(func() {
+			t.Setenv("BP_PIPENV_VERSION", "")
+		})
+
+		it("requires pipenv without a version", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+				Name: "pipenv",
+				Metadata: pipenv.BuildPlanMetadata{
+					VersionSource: "BP_PIPENV_VERSION",
+				},
+			}))
+		})
+	})
+
+	context("when the app's pyproject.toml cannot be parsed", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte("[project"), 0644)).To(Succeed())
+		})
+
+		it("warns and passes detection with the default configuration", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Provides).To(ContainElement(packit.BuildPlanProvision{Name: "pipenv"}))
+
+			Expect(buffer.String()).To(ContainSubstring("WARNING: ignoring the settings in pyproject.toml: failed to parse pyproject.toml"))
+		})
+	})
+
+	context("when the version is configured in pyproject.toml", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.paketo.pipenv]
+version = "~=2024.0"
+`), 0644)).To(Succeed())
+		})
+
+		it("requires that version, with pyproject.toml as the version source", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+				Name: "pipenv",
+				Metadata: pipenv.BuildPlanMetadata{
+					Version:       "~=2024.0",
+					VersionSource: "pyproject.toml",
+				},
+			}))
+		})
+
+		context("when BP_PIPENV_VERSION is also set", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_VERSION", "1.2.3")
+			})
+
+			it("requires the version from the environment", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+					Name: "pipenv",
+					Metadata: pipenv.BuildPlanMetadata{
+						Version:       "1.2.3",
+						VersionSource: "BP_PIPENV_VERSION",
+					},
+				}))
+			})
+		})
+	})
+
+	context("when the app has other Python packaging files", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte("{}"), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(""), 0644)).To(Succeed())
+		})
+
+		it("logs and records them", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires[2].Metadata).To(Equal(pipenv.PackagingFilesMetadata{
+				PackagingFiles: []string{"Pipfile", "Pipfile.lock", "pyproject.toml"},
+			}))
+
+			// Only the packaging files are written to the build plan.
+			encoded := bytes.NewBuffer(nil)
+			Expect(toml.NewEncoder(encoded).Encode(result.Plan.Requires[2].Metadata)).To(Succeed())
+			Expect(encoded.String()).To(Equal("packaging-files = [\"Pipfile\", \"Pipfile.lock\", \"pyproject.toml\"]\n"))
+
+			Expect(buffer.String()).To(ContainSubstring("Python packaging files: Pipfile (pipenv), Pipfile.lock (pipenv), pyproject.toml (pyproject)"))
+			Expect(buffer.String()).NotTo(ContainSubstring("WARNING"))
+		})
+
+		context("when another package manager's lockfile is present", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "requirements.txt"), []byte("flask\n"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "poetry.lock"), []byte(""), 0644)).To(Succeed())
+			})
+
+			it("warns about the conflict", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: Pipfile, Pipfile.lock coexist with requirements.txt (pip), poetry.lock (poetry): only the Pipenv files are used`))
+			})
+
+			context("when BP_PIPENV_CONFLICT_CHECK is fail", func() {
+				it.Before(func() {
+					t.Setenv("BP_PIPENV_CONFLICT_CHECK", "fail")
+				})
+
+				it("returns an error", func() {
+					_, err := detect(packit.DetectContext{
+						WorkingDir: workingDir,
+					})
+					Expect(err).To(MatchError(ContainSubstring("conflicting Python package managers: Pipfile, Pipfile.lock coexist with requirements.txt (pip), poetry.lock (poetry)")))
+				})
+			})
+
+			context("when BP_PIPENV_CONFLICT_CHECK is off", func() {
+				it.Before(func() {
+					t.Setenv("BP_PIPENV_CONFLICT_CHECK", "off")
+				})
+
+				it("neither logs nor warns", func() {
+					_, err := detect(packit.DetectContext{
+						WorkingDir: workingDir,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(buffer.String()).To(BeEmpty())
+				})
+			})
+		})
+
+		context("when BP_PIPENV_CONFLICT_CHECK is invalid", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_CONFLICT_CHECK", "sometimes")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(`invalid package manager conflict check mode "sometimes": must be one of "off", "warn" or "fail"`))
+			})
+		})
+	})
+
+	context("when the app pins a python version", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".python-version"), []byte("# pyenv\n3.12\n"), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "runtime.txt"), []byte("python-3.12.4\n"), 0644)).To(Succeed())
+		})
+
+		it("requires cpython at the pinned versions", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name:     pipenv.Pip,
+					Metadata: pipenv.BuildPlanMetadata{Build: true},
+				},
+				{
+					Name:     pipenv.CPython,
+					Metadata: pipenv.BuildPlanMetadata{Build: true},
+				},
+				{
+					Name: pipenv.PackagingFiles,
+					Metadata: pipenv.PackagingFilesMetadata{
+						PackagingFiles: []string{"Pipfile"},
+					},
+				},
+				{
+					Name: pipenv.CPython,
+					Metadata: pipenv.BuildPlanMetadata{
+						Version:       "3.12.*",
+						VersionSource: ".python-version",
+						Build:         true,
+					},
+				},
+				{
+					Name: pipenv.CPython,
+					Metadata: pipenv.BuildPlanMetadata{
+						Version:       "3.12.4",
+						VersionSource: "runtime.txt",
+						Build:         true,
+					},
+				},
+			}))
+		})
+
+		context("when .python-version selects the system python", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".python-version"), []byte("system\n"), 0644)).To(Succeed())
+				Expect(os.Remove(filepath.Join(workingDir, "runtime.txt"))).To(Succeed())
+			})
+
+			it("does not pin cpython", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(HaveLen(3))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the pins disagree", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, ".python-version"), []byte("3.11\n"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := detect(packit.DetectContext{
+						WorkingDir: workingDir,
+					})
+					Expect(err).To(MatchError(`conflicting python versions: .python-version pins "3.11" but runtime.txt pins "3.12.4"`))
+				})
+			})
+
+		})
+	})
+
+	context("when the app pins a python version that cannot be translated", func() {
+		for _, version := range []string{"pypy3.10-7.3.12", "3.12-dev", "my-virtualenv"} {
+			version := version
+
+			context(fmt.Sprintf("when .python-version is %q", version), func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, ".python-version"), []byte(version+"\n"), 0644)).To(Succeed())
+				})
+
+				it("logs and skips the pin", func() {
+					result, err := detect(packit.DetectContext{
+						WorkingDir: workingDir,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Plan.Requires).To(HaveLen(3))
+
+					Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf(`Ignoring python version %q in .python-version: not a CPython version`, version)))
+				})
+			})
+		}
+
+		context("when .python-version selects several versions", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".python-version"), []byte("3.12.4\n3.11.9\n"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "runtime.txt"), []byte("python-3.11.9\n"), 0644)).To(Succeed())
+			})
+
+			it("logs and skips the file, and keeps the runtime.txt pin", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(ContainElement(packit.BuildPlanRequirement{
+					Name: pipenv.CPython,
+					Metadata: pipenv.BuildPlanMetadata{
+						Version:       "3.11.9",
+						VersionSource: "runtime.txt",
+						Build:         true,
+					},
+				}))
+				Expect(result.Plan.Requires).To(HaveLen(4))
+
+				Expect(buffer.String()).To(ContainSubstring("Ignoring .python-version: it selects several versions (3.12.4, 3.11.9)"))
+			})
+		})
+
+		context("when runtime.txt is not a python runtime", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "runtime.txt"), []byte("java-17\n"), 0644)).To(Succeed())
+			})
+
+			it("logs and skips the pin", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(HaveLen(3))
+
+				Expect(buffer.String()).To(ContainSubstring(`Ignoring runtime "java-17" in runtime.txt: not of the form "python-3.12.4"`))
+			})
+		})
+	})
+
+	context("when BP_PIPENV_BUILD is true", func() {
+		it.Before(func() {
+			t.Setenv("BP_PIPENV_BUILD", "true")
+		})
+
+		it("requires pipenv at build time", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name:     pipenv.Pip,
+					Metadata: pipenv.BuildPlanMetadata{Build: true},
+				},
+				{
+					Name:     pipenv.CPython,
+					Metadata: pipenv.BuildPlanMetadata{Build: true},
+				},
+				{
+					Name: pipenv.PackagingFiles,
+					Metadata: pipenv.PackagingFilesMetadata{
+						PackagingFiles: []string{"Pipfile"},
+					},
+				},
+				{
+					Name:     "pipenv",
+					Metadata: pipenv.BuildPlanMetadata{Build: true},
+				},
+			}))
+		})
+	})
+
+	context("when BP_PIPENV_LAUNCH is true", func() {
+		it.Before(func() {
+			t.Setenv("BP_PIPENV_LAUNCH", "true")
+			t.Setenv("BP_PIPENV_VERSION", "1.2.3")
+		})
+
+		it("requires pipenv and cpython at launch time", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name:     pipenv.Pip,
+					Metadata: pipenv.BuildPlanMetadata{Build: true},
+				},
+				{
+					Name:     pipenv.CPython,
+					Metadata: pipenv.BuildPlanMetadata{Build: true, Launch: true},
+				},
+				{
+					Name: pipenv.PackagingFiles,
+					Metadata: pipenv.PackagingFilesMetadata{
+						PackagingFiles: []string{"Pipfile"},
+					},
+				},
+				{
+					Name: "pipenv",
+					Metadata: pipenv.BuildPlanMetadata{
+						Version:       "1.2.3",
+						VersionSource: "BP_PIPENV_VERSION",
+						Launch:        true,
+					},
+				},
+			}))
+		})
+
+		context("when BP_PIPENV_INSTALL_APP_DEPENDENCIES is also true", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_INSTALL_APP_DEPENDENCIES", "true")
+			})
+
+			it("requires pipenv only once", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(HaveLen(4))
+				Expect(result.Plan.Requires[3].Metadata).To(Equal(pipenv.BuildPlanMetadata{
+					Version:       "1.2.3",
+					VersionSource: "BP_PIPENV_VERSION",
+					Launch:        true,
+				}))
+			})
+		})
+	})
+
+	context("when BP_PIPENV_INSTALL_APP_DEPENDENCIES is true", func() {
+		it.Before(func() {
+			t.Setenv("BP_PIPENV_INSTALL_APP_DEPENDENCIES", "true")
+		})
+
+		it("requires pipenv and provides site-packages", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			requirements := []packit.BuildPlanRequirement{
+				{
+					Name: pipenv.Pip,
+					Metadata: pipenv.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+				{
+					Name: pipenv.CPython,
+					Metadata: pipenv.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+				{
+					Name: pipenv.PackagingFiles,
+					Metadata: pipenv.PackagingFilesMetadata{
+						PackagingFiles: []string{"Pipfile"},
+					},
+				},
+				{
+					Name:     "pipenv",
+					Metadata: pipenv.BuildPlanMetadata{},
+				},
+			}
+
+			Expect(result).To(Equal(packit.DetectResult{
+				Plan: packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{Name: "pipenv"},
+						{Name: "pipenv-packaging-files"},
+						{Name: "site-packages"},
+					},
+					Requires: requirements,
+					Or: []packit.BuildPlan{
+						{
+							Provides: []packit.BuildPlanProvision{
+								{Name: "pipenv"},
+								{Name: "pipenv-packaging-files"},
+							},
+							Requires: requirements,
+						},
+					},
+				},
+			}))
+		})
+
+		context("when the setting is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_INSTALL_APP_DEPENDENCIES", "sometimes")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("invalid value for $BP_PIPENV_INSTALL_APP_DEPENDENCIES")))
+			})
+		})
+	})
+	context("when BP_PIPENV_EXPORT_REQUIREMENTS is true", func() {
+		var requirements []packit.BuildPlanRequirement
+
+		it.Before(func() {
+			t.Setenv("BP_PIPENV_EXPORT_REQUIREMENTS", "true")
+
+			requirements = []packit.BuildPlanRequirement{
+				{
+					Name: pipenv.Pip,
+					Metadata: pipenv.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+				{
+					Name: pipenv.CPython,
+					Metadata: pipenv.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+				{
+					Name: pipenv.PackagingFiles,
+					Metadata: pipenv.PackagingFilesMetadata{
+						PackagingFiles: []string{"Pipfile"},
+					},
+				},
+				{
+					Name:     "pipenv",
+					Metadata: pipenv.BuildPlanMetadata{},
+				},
+			}
+		})
+
+		it("requires pipenv and provides requirements", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: "pipenv"},
+					{Name: "pipenv-packaging-files"},
+					{Name: "requirements"},
+				},
+				Requires: requirements,
+				Or: []packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{{Name: "pipenv"}, {Name: "pipenv-packaging-files"}},
+						Requires: requirements,
+					},
+				},
+			}))
+		})
+
+		context("when BP_PIPENV_INSTALL_APP_DEPENDENCIES is also true", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_INSTALL_APP_DEPENDENCIES", "true")
+			})
+
+			it("falls back through plans providing fewer of the optional provisions", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Plan).To(Equal(packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{Name: "pipenv"},
+						{Name: "pipenv-packaging-files"},
+						{Name: "site-packages"},
+						{Name: "requirements"},
+					},
+					Requires: requirements,
+					Or: []packit.BuildPlan{
+						{
+							Provides: []packit.BuildPlanProvision{{Name: "pipenv"}, {Name: "pipenv-packaging-files"}, {Name: "site-packages"}},
+							Requires: requirements,
+						},
+						{
+							Provides: []packit.BuildPlanProvision{{Name: "pipenv"}, {Name: "pipenv-packaging-files"}, {Name: "requirements"}},
+							Requires: requirements,
+						},
+						{
+							Provides: []packit.BuildPlanProvision{{Name: "pipenv"}, {Name: "pipenv-packaging-files"}},
+							Requires: requirements,
+						},
+					},
+				}))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when BP_PIPENV_BUILD is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_BUILD", "sometimes")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(`invalid value for $BP_PIPENV_BUILD: "sometimes" is not a boolean`))
+			})
+		})
+
+		context("when BP_PIPENV_LAUNCH is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_PIPENV_LAUNCH", "sometimes")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(`invalid value for $BP_PIPENV_LAUNCH: "sometimes" is not a boolean`))
+			})
+		})
+
+		context("when pyproject.toml has an invalid setting", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pyproject.toml"), []byte(`
+[tool.paketo.pipenv]
+launch = "yes"
+`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError("invalid value for tool.paketo.pipenv.launch in pyproject.toml: must be a boolean"))
+			})
+		})
+	})
 }
@@ -0,0 +1,213 @@
+package pipenv
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// DefaultDownloadCacheMaxAge is how long a download cache entry is kept
+	// after pip or pipenv wrote it when $BP_PIPENV_CACHE_MAX_AGE is not set.
+	// Reading an entry does not update its modification time, so entries age
+	// out even while they are still being used.
+	DefaultDownloadCacheMaxAge = 30 * 24 * time.Hour
+
+	// DefaultDownloadCacheMaxSize is the size, in megabytes, the download
+	// cache is pruned to when $BP_PIPENV_CACHE_MAX_SIZE is not set.
+	DefaultDownloadCacheMaxSize = 1024
+)
+
+// downloadCacheEnv returns the environment variables that point pip and
+// pipenv at the download cache in the given layer.
+func downloadCacheEnv(cachePath string) []string {
+	return []string{
+		fmt.Sprintf("PIP_CACHE_DIR=%s", filepath.Join(cachePath, "pip")),
+		fmt.Sprintf("PIPENV_CACHE_DIR=%s", filepath.Join(cachePath, "pipenv")),
+	}
+}
+
+// contributeDownloadCache returns the cache-only layer holding the pip and
+// pipenv download caches, pruned of entries written longer ago than
+// $BP_PIPENV_CACHE_MAX_AGE and, oldest first, down to
+// $BP_PIPENV_CACHE_MAX_SIZE megabytes.
+func contributeDownloadCache(context packit.BuildContext, config Configuration, logger scribe.Emitter, clock chronos.Clock) (packit.Layer, error) {
+	maxAge := DefaultDownloadCacheMaxAge
+	if value := config.CacheMaxAge; value != "" {
+		var err error
+		maxAge, err = time.ParseDuration(value)
+		if err != nil {
+			return packit.Layer{}, fmt.Errorf("invalid value for $BP_PIPENV_CACHE_MAX_AGE: %w", err)
+		}
+	}
+
+	maxSize := int64(DefaultDownloadCacheMaxSize)
+	if value := config.CacheMaxSize; value != "" {
+		var err error
+		maxSize, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || maxSize < 0 {
+			return packit.Layer{}, fmt.Errorf("invalid value for $BP_PIPENV_CACHE_MAX_SIZE: %q is not a number of megabytes", value)
+		}
+	}
+
+	cacheLayer, err := context.Layers.Get(DownloadCache)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	cacheLayer.Launch, cacheLayer.Build, cacheLayer.Cache = false, false, true
+
+	for _, dir := range []string{"pip", "pipenv"} {
+		err = os.MkdirAll(filepath.Join(cacheLayer.Path, dir), os.ModePerm)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	entries, size, err := ListDownloadCache(cacheLayer.Path)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Process("Using download cache %s", cacheLayer.Path)
+	logger.Subprocess("%d entries (%s)", len(entries), formatMegabytes(size))
+
+	removed, freed, err := PruneDownloadCache(entries, size, clock.Now().Add(-maxAge), maxSize*1024*1024)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if removed > 0 {
+		logger.Subprocess("Pruned %d entries (%s)", removed, formatMegabytes(freed))
+	}
+	logger.Break()
+
+	return cacheLayer, nil
+}
+
+// DownloadCacheEntry is a file in the download cache layer. ModTime is when
+// pip or pipenv wrote the file, as neither touches it on a cache hit.
+type DownloadCacheEntry struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ListDownloadCache returns the files under the given download cache
+// directory and their total size in bytes.
+func ListDownloadCache(root string) ([]DownloadCacheEntry, int64, error) {
+	var (
+		entries []DownloadCacheEntry
+		total   int64
+	)
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, DownloadCacheEntry{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		total += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read download cache: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// PruneDownloadCache removes the entries written before the cutoff and then,
+// oldest first, as many as needed to bring the total size down to maxSize
+// bytes. It returns the number of entries removed and the bytes freed.
+func PruneDownloadCache(entries []DownloadCacheEntry, total int64, cutoff time.Time, maxSize int64) (int, int64, error) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime.Before(entries[j].ModTime)
+	})
+
+	var (
+		removed int
+		freed   int64
+	)
+
+	for _, entry := range entries {
+		if !entry.ModTime.Before(cutoff) && total-freed <= maxSize {
+			break
+		}
+
+		err := os.Remove(entry.Path)
+		if err != nil {
+			return removed, freed, fmt.Errorf("failed to prune download cache: %w", err)
+		}
+
+		removed++
+		freed += entry.Size
+	}
+
+	return removed, freed, nil
+}
+
+func formatMegabytes(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+}
+
+// cacheUsageWriter counts the packages pip reports as served from its cache
+// ("Using cached ...") or fetched from the index ("Downloading ...") in the
+// output written to it.
+type cacheUsageWriter struct {
+	hits    int
+	misses  int
+	partial []byte
+}
+
+func (w *cacheUsageWriter) Write(b []byte) (int, error) {
+	w.partial = append(w.partial, b...)
+
+	for {
+		index := bytes.IndexByte(w.partial, '\n')
+		if index < 0 {
+			break
+		}
+
+		line := strings.TrimSpace(string(w.partial[:index]))
+		w.partial = w.partial[index+1:]
+
+		switch {
+		case strings.HasPrefix(line, "Using cached "):
+			w.hits++
+		case strings.HasPrefix(line, "Downloading "):
+			w.misses++
+		}
+	}
+
+	return len(b), nil
+}
+
+// Log reports the counted cache hits and misses, if pip reported any.
+func (w *cacheUsageWriter) Log(logger scribe.Emitter) {
+	if w.hits+w.misses == 0 {
+		return
+	}
+
+	logger.Action("Download cache: %d hits, %d misses", w.hits, w.misses)
+}
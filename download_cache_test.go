@@ -0,0 +1,120 @@
+package pipenv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDownloadCache(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		cacheDir string
+		now      time.Time
+		entries  []pipenv.DownloadCacheEntry
+		total    int64
+	)
+
+	writeEntry := func(name, content string, age time.Duration) string {
+		path := filepath.Join(cacheDir, name)
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		Expect(os.Chtimes(path, now, now.Add(-age))).To(Succeed())
+		return path
+	}
+
+	it.Before(func() {
+		cacheDir = t.TempDir()
+		now = time.Now()
+	})
+
+	context("ListDownloadCache", func() {
+		it("lists the files in the cache and their total size", func() {
+			writeEntry(filepath.Join("pip", "http", "some-entry"), "1234", time.Hour)
+			writeEntry(filepath.Join("pipenv", "other-entry"), "123456", 2*time.Hour)
+
+			entries, total, err := pipenv.ListDownloadCache(cacheDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(total).To(Equal(int64(10)))
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0].Path).To(Equal(filepath.Join(cacheDir, "pip", "http", "some-entry")))
+			Expect(entries[0].Size).To(Equal(int64(4)))
+			Expect(entries[0].ModTime).To(BeTemporally("~", now.Add(-time.Hour), time.Second))
+		})
+
+		context("when the cache cannot be read", func() {
+			it("returns an error", func() {
+				_, _, err := pipenv.ListDownloadCache(filepath.Join(cacheDir, "missing"))
+				Expect(err).To(MatchError(ContainSubstring("failed to read download cache")))
+			})
+		})
+	})
+
+	context("PruneDownloadCache", func() {
+		var oldEntry, olderEntry, recentEntry string
+
+		it.Before(func() {
+			recentEntry = writeEntry("recent-entry", "1234", time.Hour)
+			oldEntry = writeEntry("old-entry", "123456", 10*24*time.Hour)
+			olderEntry = writeEntry("older-entry", "12345678", 20*24*time.Hour)
+
+			var err error
+			entries, total, err = pipenv.ListDownloadCache(cacheDir)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("removes the entries written before the cutoff", func() {
+			removed, freed, err := pipenv.PruneDownloadCache(entries, total, now.Add(-5*24*time.Hour), total)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(2))
+			Expect(freed).To(Equal(int64(14)))
+
+			Expect(olderEntry).NotTo(BeAnExistingFile())
+			Expect(oldEntry).NotTo(BeAnExistingFile())
+			Expect(recentEntry).To(BeAnExistingFile())
+		})
+
+		it("removes the oldest entries until the cache fits the maximum size", func() {
+			removed, freed, err := pipenv.PruneDownloadCache(entries, total, now.Add(-30*24*time.Hour), 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(1))
+			Expect(freed).To(Equal(int64(8)))
+
+			Expect(olderEntry).NotTo(BeAnExistingFile())
+			Expect(oldEntry).To(BeAnExistingFile())
+			Expect(recentEntry).To(BeAnExistingFile())
+		})
+
+		it("keeps every entry when they are recent and fit", func() {
+			removed, freed, err := pipenv.PruneDownloadCache(entries, total, now.Add(-30*24*time.Hour), total)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(0))
+			Expect(freed).To(BeZero())
+		})
+
+		it("removes every entry when the maximum size is zero", func() {
+			removed, freed, err := pipenv.PruneDownloadCache(entries, total, now.Add(-30*24*time.Hour), 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(3))
+			Expect(freed).To(Equal(total))
+		})
+
+		context("when an entry cannot be removed", func() {
+			it.Before(func() {
+				Expect(os.Remove(olderEntry)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, _, err := pipenv.PruneDownloadCache(entries, total, now, 0)
+				Expect(err).To(MatchError(ContainSubstring("failed to prune download cache")))
+			})
+		})
+	})
+}
@@ -0,0 +1,28 @@
+package pipenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pipenvEnv returns the environment needed to run the pipenv installed in
+// pipenvLayerPath, whose packages are in sitePackagesPath.
+func pipenvEnv(pipenvLayerPath, sitePackagesPath string) []string {
+	return append(os.Environ(),
+		fmt.Sprintf("PATH=%s", prependPath(filepath.Join(pipenvLayerPath, "bin"), os.Getenv("PATH"))),
+		fmt.Sprintf("PYTHONPATH=%s", prependPath(sitePackagesPath, os.Getenv("PYTHONPATH"))),
+		"PIPENV_VERBOSITY=-1",
+	)
+}
+
+// prependPath prepends the path onto a list of paths such as $PATH, avoiding
+// a trailing separator when the list is empty.
+func prependPath(path, list string) string {
+	if list == "" {
+		return path
+	}
+
+	return strings.Join([]string{path, list}, string(os.PathListSeparator))
+}
@@ -0,0 +1,36 @@
+package fakes
+
+import "sync"
+
+type AppDependencyInstallProcess struct {
+	ExecuteCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			WorkingDir       string
+			PipenvLayerPath  string
+			SitePackagesPath string
+			TargetLayerPath  string
+			CachePath        string
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(string, string, string, string, string) error
+	}
+}
+
+func (f *AppDependencyInstallProcess) Execute(param1 string, param2 string, param3 string, param4 string, param5 string) error {
+	f.ExecuteCall.mutex.Lock()
+	defer f.ExecuteCall.mutex.Unlock()
+	f.ExecuteCall.CallCount++
+	f.ExecuteCall.Receives.WorkingDir = param1
+	f.ExecuteCall.Receives.PipenvLayerPath = param2
+	f.ExecuteCall.Receives.SitePackagesPath = param3
+	f.ExecuteCall.Receives.TargetLayerPath = param4
+	f.ExecuteCall.Receives.CachePath = param5
+	if f.ExecuteCall.Stub != nil {
+		return f.ExecuteCall.Stub(param1, param2, param3, param4, param5)
+	}
+	return f.ExecuteCall.Returns.Error
+}
@@ -7,24 +7,28 @@ type InstallProcess struct {
 		mutex     sync.Mutex
 		CallCount int
 		Receives  struct {
-			Version       string
-			DestLayerPath string
+			Version             string
+			DestLayerPath       string
+			CachePath           string
+			BreakSystemPackages bool
 		}
 		Returns struct {
 			Error error
 		}
-		Stub func(string, string) error
+		Stub func(string, string, string, bool) error
 	}
 }
 
-func (f *InstallProcess) Execute(param1 string, param2 string) error {
+func (f *InstallProcess) Execute(param1 string, param2 string, param3 string, param4 bool) error {
 	f.ExecuteCall.mutex.Lock()
 	defer f.ExecuteCall.mutex.Unlock()
 	f.ExecuteCall.CallCount++
 	f.ExecuteCall.Receives.Version = param1
 	f.ExecuteCall.Receives.DestLayerPath = param2
+	f.ExecuteCall.Receives.CachePath = param3
+	f.ExecuteCall.Receives.BreakSystemPackages = param4
 	if f.ExecuteCall.Stub != nil {
-		return f.ExecuteCall.Stub(param1, param2)
+		return f.ExecuteCall.Stub(param1, param2, param3, param4)
 	}
 	return f.ExecuteCall.Returns.Error
 }
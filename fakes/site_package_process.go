@@ -15,6 +15,15 @@ type SitePackageProcess struct {
 		}
 		Stub func(string) (string, error)
 	}
+	PythonVersionCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Returns   struct {
+			String string
+			Error  error
+		}
+		Stub func() (string, error)
+	}
 }
 
 func (f *SitePackageProcess) Execute(param1 string) (string, error) {
@@ -27,3 +36,12 @@ func (f *SitePackageProcess) Execute(param1 string) (string, error) {
 	}
 	return f.ExecuteCall.Returns.String, f.ExecuteCall.Returns.Error
 }
+func (f *SitePackageProcess) PythonVersion() (string, error) {
+	f.PythonVersionCall.mutex.Lock()
+	defer f.PythonVersionCall.mutex.Unlock()
+	f.PythonVersionCall.CallCount++
+	if f.PythonVersionCall.Stub != nil {
+		return f.PythonVersionCall.Stub()
+	}
+	return f.PythonVersionCall.Returns.String, f.PythonVersionCall.Returns.Error
+}
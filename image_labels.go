@@ -0,0 +1,51 @@
+package pipenv
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+// DefaultImageLabelPrefix is the prefix of the image labels describing the
+// installed pipenv, unless $BP_PIPENV_IMAGE_LABEL_PREFIX is set.
+const DefaultImageLabelPrefix = "io.paketo.pipenv"
+
+// imageLabelPrefix matches a label key prefix in reverse DNS notation, as
+// recommended by the OCI image specification.
+var imageLabelPrefix = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// imageLabels returns the image labels describing the resolved pipenv
+// dependency: its version, source checksum and package URL. Labels are added
+// unless $BP_PIPENV_IMAGE_LABELS is false, and their keys are prefixed with
+// $BP_PIPENV_IMAGE_LABEL_PREFIX, or DefaultImageLabelPrefix.
+func imageLabels(dependency postal.Dependency, config Configuration) (map[string]string, error) {
+	if !config.ImageLabels {
+		return nil, nil
+	}
+
+	prefix := DefaultImageLabelPrefix
+	if value := config.ImageLabelPrefix; value != "" {
+		if !imageLabelPrefix.MatchString(value) {
+			return nil, fmt.Errorf("invalid value for $BP_PIPENV_IMAGE_LABEL_PREFIX: %q must be in reverse DNS notation, such as %q", value, DefaultImageLabelPrefix)
+		}
+		prefix = value
+	}
+
+	checksum := dependency.SourceChecksum
+	if checksum == "" {
+		checksum = dependency.Checksum
+	}
+
+	labels := map[string]string{
+		fmt.Sprintf("%s.version", prefix): dependency.Version,
+	}
+	if checksum != "" {
+		labels[fmt.Sprintf("%s.source-checksum", prefix)] = checksum
+	}
+	if dependency.PURL != "" {
+		labels[fmt.Sprintf("%s.purl", prefix)] = dependency.PURL
+	}
+
+	return labels, nil
+}
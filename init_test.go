@@ -10,8 +10,21 @@ import (
 func TestUnitPipenv(t *testing.T) {
 	suite := spec.New("pipenv", spec.Report(report.Terminal{}))
 	suite("Detect", testDetect)
+	suite("DownloadCache", testDownloadCache)
 	suite("Build", testBuild)
 	suite("InstallProcess", testPipenvInstallProcess)
+	suite("AppInstallProcess", testPipenvAppInstallProcess)
+	suite("RequirementsProcess", testPipenvRequirementsProcess)
 	suite("SiteProcess", testSiteProcess)
+	suite("Configuration", testConfiguration)
+	suite("ConsoleScripts", testConsoleScripts)
+	suite("InstalledPackages", testInstalledPackages)
+	suite("PipfileLockCheck", testPipfileLockCheck)
+	suite("PipfileScripts", testPipfileScripts)
+	suite("PipfileValidation", testPipfileValidation)
+	suite("LaunchEnvironment", testLaunchEnvironment)
+	suite("LicensePolicy", testLicensePolicy)
+	suite("VersionResolution", testVersionResolution)
+	suite("VulnerabilityScan", testVulnerabilityScan)
 	suite.Run(t)
 }
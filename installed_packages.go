@@ -0,0 +1,150 @@
+package pipenv
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InstalledPackage describes a Python distribution installed into a
+// site-packages directory.
+type InstalledPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	License string `json:"license,omitempty"`
+}
+
+// ListInstalledPackages returns the distributions found in the given
+// site-packages directory, sorted by name. The name and version are read from
+// the METADATA file of each *.dist-info directory.
+func ListInstalledPackages(sitePackagesPath string) ([]InstalledPackage, error) {
+	distInfos, err := filepath.Glob(filepath.Join(sitePackagesPath, "*.dist-info"))
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []InstalledPackage
+	for _, distInfo := range distInfos {
+		metadata, err := parseDistInfoMetadata(filepath.Join(distInfo, "METADATA"))
+		if err != nil {
+			return nil, err
+		}
+
+		packages = append(packages, InstalledPackage{
+			Name:    metadata.Get("Name"),
+			Version: metadata.Get("Version"),
+			License: metadata.License(),
+		})
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return strings.ToLower(packages[i].Name) < strings.ToLower(packages[j].Name)
+	})
+
+	return packages, nil
+}
+
+// distInfoMetadata holds the header fields of a core metadata file. Fields
+// may appear more than once (e.g. Classifier).
+type distInfoMetadata map[string][]string
+
+// Get returns the first value of the given field, if any.
+func (m distInfoMetadata) Get(field string) string {
+	if values := m[field]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// licenseClassifiers maps the trove classifiers of common licenses to their
+// SPDX identifiers. "License :: OSI Approved :: BSD License" is left out, as
+// it does not say which BSD license applies: a package declaring only that
+// classifier has an unknown license.
+var licenseClassifiers = map[string]string{
+	"License :: OSI Approved :: Apache Software License":                       "Apache-2.0",
+	"License :: OSI Approved :: GNU Affero General Public License v3":          "AGPL-3.0-only",
+	"License :: OSI Approved :: GNU General Public License v2 (GPLv2)":         "GPL-2.0-only",
+	"License :: OSI Approved :: GNU General Public License v3 (GPLv3)":         "GPL-3.0-only",
+	"License :: OSI Approved :: GNU Lesser General Public License v2 (LGPLv2)": "LGPL-2.0-only",
+	"License :: OSI Approved :: GNU Lesser General Public License v3 (LGPLv3)": "LGPL-3.0-only",
+	"License :: OSI Approved :: ISC License (ISCL)":                            "ISC",
+	"License :: OSI Approved :: MIT License":                                   "MIT",
+	"License :: OSI Approved :: MIT No Attribution License (MIT-0)":            "MIT-0",
+	"License :: OSI Approved :: Mozilla Public License 2.0 (MPL 2.0)":          "MPL-2.0",
+	"License :: OSI Approved :: Python Software Foundation License":            "PSF-2.0",
+	"License :: OSI Approved :: The Unlicense (Unlicense)":                     "Unlicense",
+	"License :: OSI Approved :: zlib/libpng License":                           "Zlib",
+	"License :: CC0 1.0 Universal (CC0 1.0) Public Domain Dedication":          "CC0-1.0",
+}
+
+// License returns the SPDX license expression of the distribution, taken
+// from License-Expression (PEP 639), from License when that holds an
+// expression rather than free text, or from the license classifiers. It is
+// empty when the license is unknown.
+func (m distInfoMetadata) License() string {
+	if expression := m.Get("License-Expression"); expression != "" {
+		return expression
+	}
+
+	if license := m.Get("License"); license != "" {
+		if _, err := parseLicenseExpression(license); err == nil {
+			return license
+		}
+	}
+
+	var licenses []string
+	for _, classifier := range m["Classifier"] {
+		if license, ok := licenseClassifiers[classifier]; ok {
+			licenses = append(licenses, license)
+		}
+	}
+
+	return strings.Join(licenses, " OR ")
+}
+
+// parseDistInfoMetadata reads the header section of a core metadata file,
+// which uses RFC 822 style "Field: value" lines terminated by a blank line.
+func parseDistInfoMetadata(path string) (distInfoMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package metadata: %w", err)
+	}
+	defer file.Close()
+
+	metadata := distInfoMetadata{}
+
+	var previous string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+
+		// Continuation lines are indented and belong to the previous field.
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && previous != "" {
+			values := metadata[previous]
+			values[len(values)-1] = fmt.Sprintf("%s\n%s", values[len(values)-1], strings.TrimSpace(line))
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		previous = strings.TrimSpace(field)
+		metadata[previous] = append(metadata[previous], strings.TrimSpace(value))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read package metadata: %w", err)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,94 @@
+package pipenv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testInstalledPackages(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		sitePackagesPath string
+	)
+
+	it.Before(func() {
+		sitePackagesPath = t.TempDir()
+
+		writeMetadata := func(distInfo, content string) {
+			Expect(os.MkdirAll(filepath.Join(sitePackagesPath, distInfo), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(sitePackagesPath, distInfo, "METADATA"), []byte(content), 0644)).To(Succeed())
+		}
+
+		writeMetadata("virtualenv-20.26.0.dist-info", "Metadata-Version: 2.1\nName: virtualenv\nVersion: 20.26.0\n\nSome description\nName: not-a-header\n")
+		writeMetadata("Pipenv-2026.7.1.dist-info", "Metadata-Version: 2.1\nName: Pipenv\nVersion: 2026.7.1\nSummary: Python Development\n  Workflow for Humans.\n")
+		Expect(os.MkdirAll(filepath.Join(sitePackagesPath, "pipenv"), os.ModePerm)).To(Succeed())
+	})
+
+	context("ListInstalledPackages", func() {
+		it("returns the installed distributions sorted by name", func() {
+			packages, err := pipenv.ListInstalledPackages(sitePackagesPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packages).To(Equal([]pipenv.InstalledPackage{
+				{Name: "Pipenv", Version: "2026.7.1"},
+				{Name: "virtualenv", Version: "20.26.0"},
+			}))
+		})
+
+		context("when the distributions declare licenses", func() {
+			it.Before(func() {
+				sitePackagesPath = t.TempDir()
+
+				for distInfo, content := range map[string]string{
+					"expression-1.0.dist-info": "Metadata-Version: 2.4\nName: expression\nVersion: 1.0\nLicense-Expression: Apache-2.0 OR MIT\nLicense: ignored\n",
+					"spdx-1.0.dist-info":       "Metadata-Version: 2.1\nName: spdx\nVersion: 1.0\nLicense: BSD-3-Clause\n",
+					"classifier-1.0.dist-info": "Metadata-Version: 2.1\nName: classifier\nVersion: 1.0\nLicense: The MIT License, see LICENSE\nClassifier: License :: OSI Approved :: MIT License\nClassifier: Programming Language :: Python\n",
+					"unknown-1.0.dist-info":    "Metadata-Version: 2.1\nName: unknown\nVersion: 1.0\nLicense: Proprietary license text\n",
+					"bsd-1.0.dist-info":        "Metadata-Version: 2.1\nName: bsd\nVersion: 1.0\nLicense: BSD, see LICENSE\nClassifier: License :: OSI Approved :: BSD License\n",
+				} {
+					Expect(os.MkdirAll(filepath.Join(sitePackagesPath, distInfo), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(sitePackagesPath, distInfo, "METADATA"), []byte(content), 0644)).To(Succeed())
+				}
+			})
+
+			it("records them as SPDX expressions", func() {
+				packages, err := pipenv.ListInstalledPackages(sitePackagesPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(packages).To(Equal([]pipenv.InstalledPackage{
+					{Name: "bsd", Version: "1.0"},
+					{Name: "classifier", Version: "1.0", License: "MIT"},
+					{Name: "expression", Version: "1.0", License: "Apache-2.0 OR MIT"},
+					{Name: "spdx", Version: "1.0", License: "BSD-3-Clause"},
+					{Name: "unknown", Version: "1.0"},
+				}))
+			})
+		})
+
+		context("when the site-packages directory does not exist", func() {
+			it("returns no packages", func() {
+				packages, err := pipenv.ListInstalledPackages(filepath.Join(sitePackagesPath, "missing"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(packages).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when a dist-info directory has no METADATA", func() {
+				it.Before(func() {
+					Expect(os.MkdirAll(filepath.Join(sitePackagesPath, "broken-1.0.dist-info"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.ListInstalledPackages(sitePackagesPath)
+					Expect(err).To(MatchError(ContainSubstring("failed to read package metadata")))
+				})
+			})
+		})
+	})
+}
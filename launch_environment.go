@@ -0,0 +1,83 @@
+package pipenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PythonPathHelper is the name of the exec.d executable that is added to the
+// pipenv layer when it is available at launch. It is built from
+// cmd/pythonpath.
+const PythonPathHelper = "pythonpath"
+
+// LaunchEnvironment recomputes, at container start, the $PYTHONPATH and $PATH
+// for the pipenv layer at layerPath. The site packages directory baked into
+// $PYTHONPATH at build time is wrong when the interpreter at launch differs
+// from the one pipenv was installed with, for instance after a rebase, so it
+// is looked up again with the python on $PATH and replaces any site packages
+// directory of the layer already on $PYTHONPATH.
+//
+// An error is returned when the layer has no site packages for the
+// interpreter, as pipenv could not be imported.
+func LaunchEnvironment(layerPath string, siteProcess SitePackageProcess) (map[string]string, error) {
+	sitePackagesPath, err := siteProcess.Execute(layerPath)
+	if err != nil {
+		return nil, err
+	}
+	sitePackagesPath = strings.TrimSpace(sitePackagesPath)
+
+	info, err := os.Stat(sitePackagesPath)
+	if sitePackagesPath == "" || err != nil || !info.IsDir() {
+		pythonVersion, err := siteProcess.PythonVersion()
+		if err != nil {
+			return nil, err
+		}
+
+		found, err := filepath.Glob(filepath.Join(layerPath, "lib", "python*", SitePackages))
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			found = []string{"none"}
+		}
+
+		return nil, fmt.Errorf("the pipenv layer %s does not match python %s: expected site packages at %q, found: %s; rebuild the image with this python",
+			layerPath, pythonVersion, sitePackagesPath, strings.Join(found, ", "))
+	}
+
+	stale := filepath.Join(layerPath, "lib", "python*", SitePackages)
+
+	pythonPath := []string{sitePackagesPath}
+	for _, path := range filepath.SplitList(os.Getenv("PYTHONPATH")) {
+		if path == "" || path == sitePackagesPath {
+			continue
+		}
+		if matched, _ := filepath.Match(stale, path); matched {
+			continue
+		}
+		pythonPath = append(pythonPath, path)
+	}
+
+	binPath := filepath.Join(layerPath, "bin")
+	path := os.Getenv("PATH")
+	if !containsPath(filepath.SplitList(path), binPath) {
+		path = prependPath(binPath, path)
+	}
+
+	return map[string]string{
+		"PYTHONPATH": strings.Join(pythonPath, string(os.PathListSeparator)),
+		"PATH":       path,
+	}, nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+package pipenv_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/paketo-buildpacks/pipenv/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLaunchEnvironment(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		layerPath        string
+		sitePackagesPath string
+		siteProcess      *fakes.SitePackageProcess
+	)
+
+	it.Before(func() {
+		layerPath = t.TempDir()
+		sitePackagesPath = filepath.Join(layerPath, "lib", "python3.12", "site-packages")
+		Expect(os.MkdirAll(sitePackagesPath, os.ModePerm)).To(Succeed())
+
+		siteProcess = &fakes.SitePackageProcess{}
+		siteProcess.ExecuteCall.Returns.String = sitePackagesPath + "\n"
+		siteProcess.PythonVersionCall.Returns.String = "3.12.4"
+
+		t.Setenv("PYTHONPATH", "")
+		t.Setenv("PATH", "/usr/bin")
+	})
+
+	context("LaunchEnvironment", func() {
+		it("returns the site packages of the layer for the interpreter and its bin directory", func() {
+			env, err := pipenv.LaunchEnvironment(layerPath, siteProcess)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal(map[string]string{
+				"PYTHONPATH": sitePackagesPath,
+				"PATH":       filepath.Join(layerPath, "bin") + ":/usr/bin",
+			}))
+
+			Expect(siteProcess.ExecuteCall.Receives.TargetLayerPath).To(Equal(layerPath))
+		})
+
+		context("when $PYTHONPATH has the site packages baked in for another interpreter", func() {
+			it.Before(func() {
+				t.Setenv("PYTHONPATH", filepath.Join(layerPath, "lib", "python3.11", "site-packages")+":/some/other/path")
+				t.Setenv("PATH", filepath.Join(layerPath, "bin")+":/usr/bin")
+			})
+
+			it("replaces them and keeps the other paths", func() {
+				env, err := pipenv.LaunchEnvironment(layerPath, siteProcess)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(env).To(Equal(map[string]string{
+					"PYTHONPATH": sitePackagesPath + ":/some/other/path",
+					"PATH":       filepath.Join(layerPath, "bin") + ":/usr/bin",
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the site packages cannot be located", func() {
+				it.Before(func() {
+					siteProcess.ExecuteCall.Returns.Error = errors.New("failed to locate site packages")
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LaunchEnvironment(layerPath, siteProcess)
+					Expect(err).To(MatchError("failed to locate site packages"))
+				})
+			})
+
+			context("when the layer has no site packages for the interpreter", func() {
+				it.Before(func() {
+					siteProcess.ExecuteCall.Returns.String = filepath.Join(layerPath, "lib", "python3.13", "site-packages")
+					siteProcess.PythonVersionCall.Returns.String = "3.13.0"
+				})
+
+				it("returns an error naming the interpreter and the site packages of the layer", func() {
+					_, err := pipenv.LaunchEnvironment(layerPath, siteProcess)
+					Expect(err).To(MatchError(ContainSubstring("does not match python 3.13.0")))
+					Expect(err).To(MatchError(ContainSubstring(filepath.Join(layerPath, "lib", "python3.13", "site-packages"))))
+					Expect(err).To(MatchError(ContainSubstring("found: " + sitePackagesPath)))
+				})
+			})
+
+			context("when the python version cannot be determined", func() {
+				it.Before(func() {
+					siteProcess.ExecuteCall.Returns.String = ""
+					siteProcess.PythonVersionCall.Returns.Error = errors.New("failed to determine python version")
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LaunchEnvironment(layerPath, siteProcess)
+					Expect(err).To(MatchError("failed to determine python version"))
+				})
+			})
+		})
+	})
+}
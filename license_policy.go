@@ -0,0 +1,373 @@
+package pipenv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// LicenseCheckOff disables the license policy check.
+	LicenseCheckOff = "off"
+
+	// LicenseCheckWarn logs a warning listing the packages that violate the
+	// license policy.
+	LicenseCheckWarn = "warn"
+
+	// LicenseCheckFail fails the build when packages violate the license
+	// policy.
+	LicenseCheckFail = "fail"
+)
+
+// LicenseViolation describes a package whose license does not satisfy the
+// license policy.
+type LicenseViolation struct {
+	Package string
+	Version string
+	License string
+	Reason  string
+}
+
+// LicensePolicy is a list of allowed and denied SPDX license identifiers.
+type LicensePolicy struct {
+	mode  string
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// NewLicensePolicy configures a policy from the SPDX license identifiers in
+// the $BP_PIPENV_LICENSE_ALLOW and $BP_PIPENV_LICENSE_DENY lists.
+// $BP_PIPENV_LICENSE_CHECK selects whether violations warn or fail (the
+// default) the build. Without either list, the check is off.
+func NewLicensePolicy(config Configuration) (LicensePolicy, error) {
+	policy := LicensePolicy{
+		mode:  LicenseCheckFail,
+		allow: parseLicenseList(config.LicenseAllow),
+		deny:  parseLicenseList(config.LicenseDeny),
+	}
+
+	if config.LicenseCheck != "" {
+		policy.mode = config.LicenseCheck
+	}
+
+	switch policy.mode {
+	case LicenseCheckOff, LicenseCheckWarn, LicenseCheckFail:
+	default:
+		return LicensePolicy{}, fmt.Errorf("invalid license check mode %q: must be one of %q, %q or %q", policy.mode, LicenseCheckOff, LicenseCheckWarn, LicenseCheckFail)
+	}
+
+	if len(policy.allow) == 0 && len(policy.deny) == 0 {
+		policy.mode = LicenseCheckOff
+	}
+
+	return policy, nil
+}
+
+func parseLicenseList(ids []string) map[string]bool {
+	list := map[string]bool{}
+	for _, id := range ids {
+		if id = strings.Join(strings.Fields(id), " "); id != "" {
+			list[strings.ToLower(id)] = true
+		}
+	}
+
+	return list
+}
+
+// Evaluate returns the violations of the licenses the dependency declares in
+// buildpack.toml, which must all be acceptable, and of the licenses of the
+// installed packages.
+func (p LicensePolicy) Evaluate(dependency postal.Dependency, packages []InstalledPackage) []LicenseViolation {
+	if p.mode == LicenseCheckOff {
+		return nil
+	}
+
+	var violations []LicenseViolation
+
+	if len(dependency.Licenses) > 0 {
+		expression := strings.Join(dependency.Licenses, " AND ")
+		if reason := p.check(expression); reason != "" {
+			violations = append(violations, LicenseViolation{
+				Package: fmt.Sprintf("%s (buildpack.toml)", dependency.ID),
+				Version: dependency.Version,
+				License: expression,
+				Reason:  reason,
+			})
+		}
+	}
+
+	for _, pkg := range packages {
+		if reason := p.check(pkg.License); reason != "" {
+			violations = append(violations, LicenseViolation{
+				Package: pkg.Name,
+				Version: pkg.Version,
+				License: pkg.License,
+				Reason:  reason,
+			})
+		}
+	}
+
+	return violations
+}
+
+// check returns why the license expression does not satisfy the policy, or
+// an empty string when it does. An unknown license only satisfies a policy
+// that has no allow list.
+func (p LicensePolicy) check(license string) string {
+	if license == "" {
+		if len(p.allow) > 0 {
+			return "license is unknown"
+		}
+		return ""
+	}
+
+	expression, err := parseLicenseExpression(license)
+	if err != nil {
+		if len(p.allow) > 0 {
+			return fmt.Sprintf("license is not a valid SPDX expression: %s", err)
+		}
+		return ""
+	}
+
+	if !expression.satisfies(p.accepts) {
+		if len(p.allow) > 0 {
+			return "license is not allowed"
+		}
+		return "license is denied"
+	}
+
+	return ""
+}
+
+// accepts reports whether the license identifier is acceptable. A license
+// with an exception also matches the entries for the license alone.
+func (p LicensePolicy) accepts(id string) bool {
+	ids := []string{strings.ToLower(id)}
+	if license, _, found := strings.Cut(ids[0], " with "); found {
+		ids = append(ids, license)
+	}
+
+	for _, id := range ids {
+		if p.deny[id] {
+			return false
+		}
+	}
+
+	if len(p.allow) == 0 {
+		return true
+	}
+
+	for _, id := range ids {
+		if p.allow[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check evaluates the policy and, depending on the mode, logs the violations
+// as a warning or returns them as an error. A nil list of packages means that
+// the installed packages are unknown, which is treated the same way rather
+// than passing the check.
+func (p LicensePolicy) Check(dependency postal.Dependency, packages []InstalledPackage, logger scribe.Emitter) error {
+	if p.mode == LicenseCheckOff {
+		return nil
+	}
+
+	logger.Process("Checking package licenses")
+
+	if packages == nil {
+		message := "the packages installed into the pipenv layer are unknown"
+		if p.mode == LicenseCheckFail {
+			return fmt.Errorf("failed to check package licenses: %s", message)
+		}
+
+		logger.Subprocess("WARNING: %s", message)
+		logger.Break()
+
+		return nil
+	}
+
+	violations := p.Evaluate(dependency, packages)
+	if len(violations) == 0 {
+		logger.Subprocess("All package licenses are allowed")
+		logger.Break()
+		return nil
+	}
+
+	var lines []string
+	for _, violation := range violations {
+		license := violation.License
+		if license == "" {
+			license = "UNKNOWN"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s): %s", violation.Package, violation.Version, license, violation.Reason))
+	}
+
+	message := fmt.Sprintf("%d packages violate the license policy:\n  %s", len(violations), strings.Join(lines, "\n  "))
+	if p.mode == LicenseCheckFail {
+		return fmt.Errorf("%s", message)
+	}
+
+	logger.Subprocess("WARNING: %s", message)
+	logger.Break()
+
+	return nil
+}
+
+// licenseExpression is a parsed SPDX license expression: either a license
+// identifier (with an optional exception) or an AND/OR of subexpressions.
+type licenseExpression struct {
+	operator string
+	id       string
+	operands []licenseExpression
+}
+
+// satisfies reports whether the expression can be satisfied using only
+// acceptable licenses: every operand of an AND and at least one operand of
+// an OR must be acceptable.
+func (e licenseExpression) satisfies(acceptable func(string) bool) bool {
+	switch e.operator {
+	case "AND":
+		for _, operand := range e.operands {
+			if !operand.satisfies(acceptable) {
+				return false
+			}
+		}
+		return true
+	case "OR":
+		for _, operand := range e.operands {
+			if operand.satisfies(acceptable) {
+				return true
+			}
+		}
+		return false
+	default:
+		return acceptable(e.id)
+	}
+}
+
+// parseLicenseExpression parses an SPDX license expression such as
+// "(MIT OR Apache-2.0) AND GPL-2.0-only WITH Classpath-exception-2.0". AND
+// binds tighter than OR. Exceptions are kept as part of the identifier, so
+// the policy can list either "GPL-2.0-only" or the full
+// "GPL-2.0-only WITH Classpath-exception-2.0".
+func parseLicenseExpression(expression string) (licenseExpression, error) {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression))
+	parser := licenseParser{tokens: tokens}
+
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return licenseExpression{}, err
+	}
+
+	if parser.position < len(tokens) {
+		return licenseExpression{}, fmt.Errorf("unexpected %q", tokens[parser.position])
+	}
+
+	return parsed, nil
+}
+
+type licenseParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *licenseParser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+
+	return ""
+}
+
+func (p *licenseParser) parseOr() (licenseExpression, error) {
+	return p.parseOperator("OR", p.parseAnd)
+}
+
+func (p *licenseParser) parseAnd() (licenseExpression, error) {
+	return p.parseOperator("AND", p.parseTerm)
+}
+
+func (p *licenseParser) parseOperator(operator string, operand func() (licenseExpression, error)) (licenseExpression, error) {
+	first, err := operand()
+	if err != nil {
+		return licenseExpression{}, err
+	}
+
+	operands := []licenseExpression{first}
+	for strings.EqualFold(p.peek(), operator) {
+		p.position++
+
+		next, err := operand()
+		if err != nil {
+			return licenseExpression{}, err
+		}
+		operands = append(operands, next)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+
+	return licenseExpression{operator: operator, operands: operands}, nil
+}
+
+func (p *licenseParser) parseTerm() (licenseExpression, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return licenseExpression{}, fmt.Errorf("unexpected end of expression")
+	case token == "(":
+		p.position++
+
+		expression, err := p.parseOr()
+		if err != nil {
+			return licenseExpression{}, err
+		}
+
+		if p.peek() != ")" {
+			return licenseExpression{}, fmt.Errorf("missing closing parenthesis")
+		}
+		p.position++
+
+		return expression, nil
+	case !isLicenseID(token):
+		return licenseExpression{}, fmt.Errorf("unexpected %q", token)
+	}
+	p.position++
+
+	if strings.EqualFold(p.peek(), "WITH") {
+		p.position++
+
+		exception := p.peek()
+		if !isLicenseID(exception) {
+			return licenseExpression{}, fmt.Errorf("missing exception after WITH")
+		}
+		p.position++
+
+		return licenseExpression{id: fmt.Sprintf("%s WITH %s", token, exception)}, nil
+	}
+
+	return licenseExpression{id: token}, nil
+}
+
+// isLicenseID reports whether the token is a valid SPDX license or exception
+// identifier rather than an operator or free text.
+func isLicenseID(token string) bool {
+	switch strings.ToUpper(token) {
+	case "", "AND", "OR", "WITH", "(", ")":
+		return false
+	}
+
+	for _, r := range token {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '+' || r == ':') {
+			return false
+		}
+	}
+
+	return true
+}
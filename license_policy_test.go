@@ -0,0 +1,200 @@
+package pipenv_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLicensePolicy(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dependency postal.Dependency
+		packages   []pipenv.InstalledPackage
+		config     pipenv.Configuration
+	)
+
+	it.Before(func() {
+		config = pipenv.Configuration{}
+
+		dependency = postal.Dependency{
+			ID:       "pipenv",
+			Version:  "2026.7.1",
+			Licenses: []string{"MIT", "MIT-0"},
+		}
+
+		packages = []pipenv.InstalledPackage{
+			{Name: "pipenv", Version: "2026.7.1", License: "MIT"},
+			{Name: "certifi", Version: "2026.1.1", License: "MPL-2.0"},
+			{Name: "dual", Version: "1.0", License: "(Apache-2.0 OR GPL-3.0-only) AND BSD-3-Clause"},
+			{Name: "excepted", Version: "1.0", License: "GPL-2.0-only WITH Classpath-exception-2.0"},
+			{Name: "mystery", Version: "1.0"},
+		}
+	})
+
+	context("Evaluate", func() {
+		context("when no lists are set", func() {
+			it("reports no violations", func() {
+				policy, err := pipenv.NewLicensePolicy(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(policy.Evaluate(dependency, packages)).To(BeEmpty())
+			})
+		})
+
+		context("with an allow list", func() {
+			it.Before(func() {
+				config.LicenseAllow = []string{"mit", " MIT-0", "Apache-2.0", "BSD-3-Clause", "GPL-2.0-only  WITH Classpath-exception-2.0"}
+			})
+
+			it("reports the packages that cannot be satisfied with allowed licenses", func() {
+				policy, err := pipenv.NewLicensePolicy(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(policy.Evaluate(dependency, packages)).To(Equal([]pipenv.LicenseViolation{
+					{Package: "certifi", Version: "2026.1.1", License: "MPL-2.0", Reason: "license is not allowed"},
+					{Package: "mystery", Version: "1.0", License: "", Reason: "license is unknown"},
+				}))
+			})
+		})
+
+		context("with a deny list", func() {
+			it.Before(func() {
+				config.LicenseDeny = []string{"GPL-3.0-only", "GPL-2.0-only", "MIT-0"}
+			})
+
+			it("reports the packages that require a denied license", func() {
+				policy, err := pipenv.NewLicensePolicy(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(policy.Evaluate(dependency, packages)).To(Equal([]pipenv.LicenseViolation{
+					{Package: "pipenv (buildpack.toml)", Version: "2026.7.1", License: "MIT AND MIT-0", Reason: "license is denied"},
+					{Package: "excepted", Version: "1.0", License: "GPL-2.0-only WITH Classpath-exception-2.0", Reason: "license is denied"},
+				}))
+			})
+		})
+
+		context("when a license is not a valid expression", func() {
+			it.Before(func() {
+				config.LicenseAllow = []string{"MIT"}
+				packages = []pipenv.InstalledPackage{
+					{Name: "broken", Version: "1.0", License: "(MIT OR"},
+				}
+			})
+
+			it("reports it", func() {
+				policy, err := pipenv.NewLicensePolicy(config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(policy.Evaluate(postal.Dependency{}, packages)).To(Equal([]pipenv.LicenseViolation{
+					{Package: "broken", Version: "1.0", License: "(MIT OR", Reason: "license is not a valid SPDX expression: unexpected end of expression"},
+				}))
+			})
+		})
+	})
+
+	context("Check", func() {
+		var (
+			buffer *bytes.Buffer
+			logger scribe.Emitter
+		)
+
+		it.Before(func() {
+			buffer = bytes.NewBuffer(nil)
+			logger = scribe.NewEmitter(buffer)
+
+			config.LicenseAllow = []string{"MIT", "MIT-0"}
+			packages = packages[:2]
+		})
+
+		it("fails listing the offending packages", func() {
+			policy, err := pipenv.NewLicensePolicy(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = policy.Check(dependency, packages, logger)
+			Expect(err).To(MatchError("1 packages violate the license policy:\n  certifi 2026.1.1 (MPL-2.0): license is not allowed"))
+		})
+
+		context("when all licenses are allowed", func() {
+			it.Before(func() {
+				packages = packages[:1]
+			})
+
+			it("logs that the check passed", func() {
+				policy, err := pipenv.NewLicensePolicy(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(policy.Check(dependency, packages, logger)).To(Succeed())
+				Expect(buffer.String()).To(ContainSubstring("All package licenses are allowed"))
+			})
+		})
+
+		context("when BP_PIPENV_LICENSE_CHECK is warn", func() {
+			it.Before(func() {
+				config.LicenseCheck = "warn"
+			})
+
+			it("logs a warning", func() {
+				policy, err := pipenv.NewLicensePolicy(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(policy.Check(dependency, packages, logger)).To(Succeed())
+				Expect(buffer.String()).To(ContainSubstring("WARNING: 1 packages violate the license policy:\n      certifi 2026.1.1 (MPL-2.0): license is not allowed"))
+			})
+		})
+
+		context("when the installed packages are unknown", func() {
+			it("returns an error", func() {
+				policy, err := pipenv.NewLicensePolicy(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = policy.Check(dependency, nil, logger)
+				Expect(err).To(MatchError("failed to check package licenses: the packages installed into the pipenv layer are unknown"))
+			})
+
+			context("when BP_PIPENV_LICENSE_CHECK is warn", func() {
+				it.Before(func() {
+					config.LicenseCheck = "warn"
+				})
+
+				it("logs a warning", func() {
+					policy, err := pipenv.NewLicensePolicy(config)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(policy.Check(dependency, nil, logger)).To(Succeed())
+					Expect(buffer.String()).To(ContainSubstring("WARNING: the packages installed into the pipenv layer are unknown"))
+				})
+			})
+		})
+
+		context("when BP_PIPENV_LICENSE_CHECK is off", func() {
+			it.Before(func() {
+				config.LicenseCheck = "off"
+			})
+
+			it("skips the check", func() {
+				policy, err := pipenv.NewLicensePolicy(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(policy.Check(dependency, packages, logger)).To(Succeed())
+				Expect(buffer.String()).To(BeEmpty())
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when BP_PIPENV_LICENSE_CHECK is invalid", func() {
+			it.Before(func() {
+				config.LicenseCheck = "strict"
+			})
+
+			it("returns an error", func() {
+				_, err := pipenv.NewLicensePolicy(config)
+				Expect(err).To(MatchError(`invalid license check mode "strict": must be one of "off", "warn" or "fail"`))
+			})
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package pipenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// ConflictCheckOff disables the check for other package managers' files.
+	ConflictCheckOff = "off"
+
+	// ConflictCheckWarn logs a warning when Pipenv files coexist with another
+	// package manager's lockfile.
+	ConflictCheckWarn = "warn"
+
+	// ConflictCheckFail fails detection when Pipenv files coexist with
+	// another package manager's lockfile.
+	ConflictCheckFail = "fail"
+)
+
+// packagingFile is a Python packaging file, the package manager it belongs
+// to, and whether it pins the app's dependencies the way Pipfile.lock does.
+type packagingFile struct {
+	Name     string
+	Manager  string
+	Lockfile bool
+}
+
+// packagingFiles are the Python packaging files that are looked for in the
+// app, in the order they are reported.
+var packagingFiles = []packagingFile{
+	{Name: "Pipfile", Manager: Pipenv},
+	{Name: "Pipfile.lock", Manager: Pipenv, Lockfile: true},
+	{Name: "requirements.txt", Manager: "pip", Lockfile: true},
+	{Name: "poetry.lock", Manager: "poetry", Lockfile: true},
+	{Name: "uv.lock", Manager: "uv", Lockfile: true},
+	{Name: "pdm.lock", Manager: "pdm", Lockfile: true},
+	{Name: "pyproject.toml", Manager: "pyproject"},
+	{Name: "setup.py", Manager: "setuptools"},
+	{Name: "environment.yml", Manager: "conda"},
+}
+
+// inventoryPackagingFiles returns the Python packaging files in workingDir.
+func inventoryPackagingFiles(workingDir string) ([]packagingFile, error) {
+	var found []packagingFile
+	for _, file := range packagingFiles {
+		_, err := os.Stat(filepath.Join(workingDir, file.Name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to inventory packaging files: %w", err)
+		}
+		found = append(found, file)
+	}
+
+	return found, nil
+}
+
+// checkPackagingConflicts logs the Python packaging files found in the app.
+// When Pipenv files coexist with another package manager's lockfile, which
+// this buildpack ignores, it logs a warning or, depending on the mode,
+// returns an error.
+func checkPackagingConflicts(files []packagingFile, mode string, logger scribe.Emitter) error {
+	switch mode {
+	case "":
+		mode = ConflictCheckWarn
+	case ConflictCheckOff, ConflictCheckWarn, ConflictCheckFail:
+	default:
+		return fmt.Errorf("invalid package manager conflict check mode %q: must be one of %q, %q or %q", mode, ConflictCheckOff, ConflictCheckWarn, ConflictCheckFail)
+	}
+
+	if len(files) == 0 || mode == ConflictCheckOff {
+		return nil
+	}
+
+	var names, pipenvFiles, others []string
+	for _, file := range files {
+		names = append(names, fmt.Sprintf("%s (%s)", file.Name, file.Manager))
+		switch {
+		case file.Manager == Pipenv:
+			pipenvFiles = append(pipenvFiles, file.Name)
+		case file.Lockfile:
+			others = append(others, fmt.Sprintf("%s (%s)", file.Name, file.Manager))
+		}
+	}
+
+	logger.Process("Python packaging files: %s", strings.Join(names, ", "))
+
+	if len(pipenvFiles) == 0 || len(others) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s coexist with %s: only the Pipenv files are used, so remove the other lockfiles or set $BP_PIPENV_CONFLICT_CHECK to %q",
+		strings.Join(pipenvFiles, ", "), strings.Join(others, ", "), ConflictCheckOff)
+	if mode == ConflictCheckFail {
+		return fmt.Errorf("conflicting Python package managers: %s", message)
+	}
+
+	logger.Subprocess("WARNING: %s", message)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package pipenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// PipenvAppInstallProcess installs the application dependencies recorded in
+// Pipfile.lock into a virtualenv.
+type PipenvAppInstallProcess struct {
+	python Executable
+	pipenv Executable
+	logger scribe.Emitter
+}
+
+// NewPipenvAppInstallProcess creates a PipenvAppInstallProcess instance from
+// a python and a pipenv Executable.
+func NewPipenvAppInstallProcess(python, pipenv Executable, logger scribe.Emitter) PipenvAppInstallProcess {
+	return PipenvAppInstallProcess{
+		python: python,
+		pipenv: pipenv,
+		logger: logger,
+	}
+}
+
+// Execute creates a virtualenv at targetLayerPath and runs
+// `pipenv install --deploy` in workingDir to install the locked dependencies
+// into it. The pipenv installation in pipenvLayerPath, whose packages are in
+// sitePackagesPath, is used to run pipenv, with the download caches in
+// cachePath.
+func (p PipenvAppInstallProcess) Execute(workingDir, pipenvLayerPath, sitePackagesPath, targetLayerPath, cachePath string) error {
+	buffer := bytes.NewBuffer(nil)
+	cacheUsage := &cacheUsageWriter{}
+	output := io.MultiWriter(buffer, cacheUsage, p.logger.Debug.DetailWriter)
+
+	p.logger.Debug.Subprocess("Running 'python -m venv %s'", targetLayerPath)
+	err := p.python.Execute(pexec.Execution{
+		Args:   []string{"-m", "venv", targetLayerPath},
+		Stdout: output,
+		Stderr: output,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create virtualenv:\n%s\nerror: %w", tail(buffer.String(), failureTailLines), err)
+	}
+
+	env := append(pipenvEnv(pipenvLayerPath, sitePackagesPath),
+		// Make pipenv install into the virtualenv created above rather than
+		// one it manages itself.
+		fmt.Sprintf("VIRTUAL_ENV=%s", targetLayerPath),
+	)
+	env = append(env, downloadCacheEnv(cachePath)...)
+
+	args := []string{"install", "--deploy"}
+	p.logger.Debug.Subprocess("Running 'pipenv %s'", strings.Join(args, " "))
+
+	buffer.Reset()
+	err = p.pipenv.Execute(pexec.Execution{
+		Args:   args,
+		Dir:    workingDir,
+		Env:    env,
+		Stdout: output,
+		Stderr: output,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install application dependencies:\n%s\nerror: %w", tail(buffer.String(), failureTailLines), err)
+	}
+
+	cacheUsage.Log(p.logger)
+
+	return nil
+}
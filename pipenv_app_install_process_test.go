@@ -0,0 +1,103 @@
+package pipenv_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/paketo-buildpacks/pipenv/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPipenvAppInstallProcess(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir      string
+		pipenvLayerPath string
+		targetLayerPath string
+		cachePath       string
+		python          *fakes.Executable
+		pipenvExec      *fakes.Executable
+
+		process pipenv.PipenvAppInstallProcess
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+		pipenvLayerPath = t.TempDir()
+		targetLayerPath = t.TempDir()
+		cachePath = t.TempDir()
+
+		t.Setenv("PATH", "/some/bin")
+		t.Setenv("PYTHONPATH", "")
+
+		python = &fakes.Executable{}
+		pipenvExec = &fakes.Executable{}
+
+		process = pipenv.NewPipenvAppInstallProcess(python, pipenvExec, scribe.NewEmitter(bytes.NewBuffer(nil)))
+	})
+
+	context("Execute", func() {
+		it("creates a virtualenv and installs the locked dependencies into it", func() {
+			err := process.Execute(workingDir, pipenvLayerPath, "/some/site-packages", targetLayerPath, cachePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(python.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"-m", "venv", targetLayerPath}))
+
+			Expect(pipenvExec.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"install", "--deploy"}))
+			Expect(pipenvExec.ExecuteCall.Receives.Execution.Dir).To(Equal(workingDir))
+			Expect(pipenvExec.ExecuteCall.Receives.Execution.Env).To(ContainElements(
+				fmt.Sprintf("PATH=%s:/some/bin", filepath.Join(pipenvLayerPath, "bin")),
+				"PYTHONPATH=/some/site-packages",
+				fmt.Sprintf("VIRTUAL_ENV=%s", targetLayerPath),
+				fmt.Sprintf("PIP_CACHE_DIR=%s", filepath.Join(cachePath, "pip")),
+				fmt.Sprintf("PIPENV_CACHE_DIR=%s", filepath.Join(cachePath, "pipenv")),
+			))
+		})
+
+		context("failure cases", func() {
+			context("when the virtualenv cannot be created", func() {
+				it.Before(func() {
+					python.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						_, err := fmt.Fprintln(execution.Stderr, "venv output")
+						Expect(err).NotTo(HaveOccurred())
+						return errors.New("venv failed")
+					}
+				})
+
+				it("returns an error", func() {
+					err := process.Execute(workingDir, pipenvLayerPath, "/some/site-packages", targetLayerPath, cachePath)
+					Expect(err).To(MatchError(ContainSubstring("failed to create virtualenv")))
+					Expect(err).To(MatchError(ContainSubstring("venv output")))
+					Expect(err).To(MatchError(ContainSubstring("venv failed")))
+					Expect(pipenvExec.ExecuteCall.CallCount).To(Equal(0))
+				})
+			})
+
+			context("when pipenv install fails", func() {
+				it.Before(func() {
+					pipenvExec.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						_, err := fmt.Fprintln(execution.Stderr, "pipenv output")
+						Expect(err).NotTo(HaveOccurred())
+						return errors.New("pipenv failed")
+					}
+				})
+
+				it("returns an error", func() {
+					err := process.Execute(workingDir, pipenvLayerPath, "/some/site-packages", targetLayerPath, cachePath)
+					Expect(err).To(MatchError(ContainSubstring("failed to install application dependencies")))
+					Expect(err).To(MatchError(ContainSubstring("pipenv output")))
+					Expect(err).To(MatchError(ContainSubstring("pipenv failed")))
+				})
+			})
+		})
+	})
+}
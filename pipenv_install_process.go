@@ -3,9 +3,15 @@ package pipenv
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
 
 //go:generate faux --interface Executable --output fakes/executable.go
@@ -15,33 +21,253 @@ type Executable interface {
 	Execute(pexec.Execution) error
 }
 
+// failureTailLines is the number of trailing lines of installer output
+// included in the error returned when an installation fails.
+const failureTailLines = 50
+
+// externallyManagedMarker is the file that PEP 668 places in the standard
+// library directory of Python installations managed by another tool, such as
+// a distribution's package manager. pip refuses to install into them.
+const externallyManagedMarker = "EXTERNALLY-MANAGED"
+
+// Strategies used to install pipenv.
+const (
+	// InstallStrategyUser installs pipenv with "pip install --user" into a
+	// user base in the layer.
+	InstallStrategyUser = "user"
+
+	// InstallStrategyVenv installs pipenv into a virtual environment created
+	// in the layer, which PEP 668 markers do not apply to.
+	InstallStrategyVenv = "venv"
+
+	// InstallStrategyBreakSystemPackages installs pipenv with "pip install
+	// --user --break-system-packages" despite the PEP 668 marker.
+	InstallStrategyBreakSystemPackages = "break-system-packages"
+)
+
 type PipenvInstallProcess struct {
 	executable Executable
+	python     Executable
+	logger     scribe.Emitter
+	debug      bool
 }
 
-// NewPipenvInstallProcess creates a PipenvInstallProcess instance.
-func NewPipenvInstallProcess(executable Executable) PipenvInstallProcess {
+// NewPipenvInstallProcess creates a PipenvInstallProcess instance that
+// installs pipenv with the pip executable, and inspects and creates virtual
+// environments with the python executable. Debug output is produced when
+// $BP_LOG_LEVEL is DEBUG, as for the logger.
+func NewPipenvInstallProcess(executable, python Executable, logger scribe.Emitter) PipenvInstallProcess {
 	return PipenvInstallProcess{
 		executable: executable,
+		python:     python,
+		logger:     logger,
+		debug:      strings.EqualFold(os.Getenv("BP_LOG_LEVEL"), "DEBUG"),
 	}
 }
 
 // Execute installs the provided version of pipenv from the internet into the
-// layer path designated by targetLayerPath
-func (p PipenvInstallProcess) Execute(version, targetLayerPath string) error {
+// layer path designated by targetLayerPath, using the pip download cache in
+// cachePath.
+//
+// When the Python installation is marked as externally managed (PEP 668),
+// pipenv is installed into a virtual environment in the layer instead of a
+// user base, unless breakSystemPackages is true. Both layouts
+// put pipenv's scripts in the bin directory and its packages in the user site
+// packages directory of the layer. The strategy used is logged. Once pipenv
+// is installed into a virtual environment, its interpreter links, activation
+// scripts and pyvenv.cfg are removed, so that its python does not shadow the
+// CPython on $PATH, and pipenv's scripts are rewritten with
+// RelocateConsoleScripts to run with that CPython rather than the removed
+// interpreter. The layer then has the same layout as a user base.
+//
+// At the debug log level, the pip and python versions, the full command line
+// and the (sanitized) environment are logged, and the output of pip is
+// streamed as it is produced.
+func (p PipenvInstallProcess) Execute(version, targetLayerPath, cachePath string, breakSystemPackages bool) error {
+	strategy, err := p.installStrategy(breakSystemPackages)
+	if err != nil {
+		return err
+	}
+
+	// Set the PYTHONUSERBASE to ensure that pip is installed to the newly created target layer.
+	env := append(os.Environ(), fmt.Sprintf("PYTHONUSERBASE=%s", targetLayerPath))
+	env = append(env, downloadCacheEnv(cachePath)...)
+
+	var args []string
+	var venvEntries []os.DirEntry
+	switch strategy {
+	case InstallStrategyVenv:
+		p.logger.Action("Python is externally managed (PEP 668): installing into a virtual environment")
+
+		buffer := bytes.NewBuffer(nil)
+		err = p.python.Execute(pexec.Execution{
+			Args:   []string{"-m", "venv", "--without-pip", targetLayerPath},
+			Env:    env,
+			Stdout: buffer,
+			Stderr: buffer,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create virtual environment:\n%s\nerror: %w", buffer.String(), err)
+		}
+
+		venvEntries, err = os.ReadDir(filepath.Join(targetLayerPath, "bin"))
+		if err != nil {
+			return fmt.Errorf("failed to read virtual environment: %w", err)
+		}
+
+		// pip runs itself with the virtual environment's interpreter, so the
+		// marker of the base installation does not apply.
+		args = []string{"--python", filepath.Join(targetLayerPath, "bin", "python"), "install", fmt.Sprintf("pipenv==%s", version)}
+	case InstallStrategyBreakSystemPackages:
+		p.logger.Action("Python is externally managed (PEP 668): installing with --break-system-packages as $BP_PIPENV_BREAK_SYSTEM_PACKAGES is true")
+		args = []string{"install", fmt.Sprintf("pipenv==%s", version), "--user", "--break-system-packages"}
+	default:
+		p.logger.Action("Installing into a user base")
+		args = []string{"install", fmt.Sprintf("pipenv==%s", version), "--user"}
+	}
+
+	p.logger.Debug.Subprocess("Running 'pip %s'", strings.Join(args, " "))
+	p.logger.Debug.Action("Environment:")
+	for _, variable := range sanitizeEnvironment(env) {
+		p.logger.Debug.Detail("%s", variable)
+	}
+
+	// Running pip for its version is only worth it when it is logged.
+	if p.debug {
+		versionBuffer := bytes.NewBuffer(nil)
+		err = p.executable.Execute(pexec.Execution{
+			Args:   []string{"--version"},
+			Env:    env,
+			Stdout: versionBuffer,
+			Stderr: versionBuffer,
+		})
+		if err == nil {
+			p.logger.Debug.Action("Using %s", strings.TrimSpace(versionBuffer.String()))
+		}
+	}
+
+	// Capture the output for error reporting, while also streaming it to the
+	// debug log as it is produced.
 	buffer := bytes.NewBuffer(nil)
+	cacheUsage := &cacheUsageWriter{}
+	output := io.MultiWriter(buffer, cacheUsage, p.logger.Debug.DetailWriter)
 
-	err := p.executable.Execute(pexec.Execution{
-		Args: []string{"install", fmt.Sprintf("pipenv==%s", version), "--user"},
-		// Set the PYTHONUSERBASE to ensure that pip is installed to the newly created target layer.
-		Env:    append(os.Environ(), fmt.Sprintf("PYTHONUSERBASE=%s", targetLayerPath)),
-		Stdout: buffer,
-		Stderr: buffer,
+	err = p.executable.Execute(pexec.Execution{
+		Args:   args,
+		Env:    env,
+		Stdout: output,
+		Stderr: output,
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to configure pipenv:\n%s\nerror: %w", buffer.String(), err)
+		return fmt.Errorf("failed to configure pipenv:\n%s\nerror: %w", tail(buffer.String(), failureTailLines), err)
+	}
+
+	cacheUsage.Log(p.logger)
+
+	if strategy == InstallStrategyVenv {
+		return removeVirtualEnvironment(targetLayerPath, venvEntries)
 	}
 
 	return nil
 }
+
+// removeVirtualEnvironment removes the given bin directory entries and the
+// pyvenv.cfg of the virtual environment at path, and rewrites the console
+// scripts that pip generated for its interpreter.
+func removeVirtualEnvironment(path string, entries []os.DirEntry) error {
+	for _, entry := range entries {
+		err := os.RemoveAll(filepath.Join(path, "bin", entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to remove %s from virtual environment: %w", entry.Name(), err)
+		}
+	}
+
+	err := os.RemoveAll(filepath.Join(path, "pyvenv.cfg"))
+	if err != nil {
+		return fmt.Errorf("failed to remove pyvenv.cfg from virtual environment: %w", err)
+	}
+
+	_, err = RelocateConsoleScripts(filepath.Join(path, "bin"))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// installStrategy chooses how to install pipenv, based on whether the
+// standard library directory of the Python installation contains a PEP 668
+// EXTERNALLY-MANAGED marker and on whether system packages may be broken.
+func (p PipenvInstallProcess) installStrategy(breakSystemPackages bool) (string, error) {
+	buffer := bytes.NewBuffer(nil)
+	stdlib := bytes.NewBuffer(nil)
+	err := p.python.Execute(pexec.Execution{
+		Args:   []string{"-c", "import sysconfig; print(sysconfig.get_path('stdlib'))"},
+		Stdout: stdlib,
+		Stderr: buffer,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the python standard library:\n%s\nerror: %w", buffer.String(), err)
+	}
+
+	marker := filepath.Join(strings.TrimSpace(stdlib.String()), externallyManagedMarker)
+	_, err = os.Stat(marker)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return InstallStrategyUser, nil
+		}
+		return "", fmt.Errorf("failed to check for %s: %w", marker, err)
+	}
+
+	if breakSystemPackages {
+		return InstallStrategyBreakSystemPackages, nil
+	}
+
+	return InstallStrategyVenv, nil
+}
+
+// sanitizeEnvironment returns a sorted copy of the given environment in which
+// the values of variables that look like they hold credentials are masked,
+// and any passwords embedded in URLs are redacted.
+func sanitizeEnvironment(env []string) []string {
+	var sanitized []string
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+
+		upper := strings.ToUpper(name)
+		for _, marker := range []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL", "AUTH", "API_KEY", "ACCESS_KEY", "PRIVATE_KEY"} {
+			if strings.Contains(upper, marker) {
+				value = "***"
+				break
+			}
+		}
+
+		if strings.Contains(value, "@") {
+			var fields []string
+			for _, field := range strings.Fields(value) {
+				if u, err := url.Parse(field); err == nil && u.User != nil {
+					field = u.Redacted()
+				}
+				fields = append(fields, field)
+			}
+			value = strings.Join(fields, " ")
+		}
+
+		sanitized = append(sanitized, fmt.Sprintf("%s=%s", name, value))
+	}
+
+	sort.Strings(sanitized)
+
+	return sanitized
+}
+
+// tail returns at most the last n lines of the given output.
+func tail(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) <= n {
+		return output
+	}
+
+	return fmt.Sprintf("... (%d lines omitted)\n%s\n", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
@@ -0,0 +1,62 @@
+package pipenv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// PipenvRequirementsProcess exports the dependencies locked in Pipfile.lock
+// as a pip requirements file.
+type PipenvRequirementsProcess struct {
+	pipenv Executable
+	logger scribe.Emitter
+}
+
+// NewPipenvRequirementsProcess creates a PipenvRequirementsProcess instance
+// from a pipenv Executable.
+func NewPipenvRequirementsProcess(pipenv Executable, logger scribe.Emitter) PipenvRequirementsProcess {
+	return PipenvRequirementsProcess{
+		pipenv: pipenv,
+		logger: logger,
+	}
+}
+
+// Execute runs `pipenv requirements --hash` in workingDir and writes its
+// output to outputPath. When dev is true, the dev-packages are included, and
+// when categories are given, only those package categories are exported.
+func (p PipenvRequirementsProcess) Execute(workingDir, pipenvLayerPath, sitePackagesPath, outputPath string, dev bool, categories []string) error {
+	args := []string{"requirements", "--hash"}
+	if dev {
+		args = append(args, "--dev")
+	}
+	if len(categories) > 0 {
+		args = append(args, "--categories", strings.Join(categories, " "))
+	}
+
+	p.logger.Debug.Subprocess("Running 'pipenv %s'", strings.Join(args, " "))
+
+	requirements := bytes.NewBuffer(nil)
+	buffer := bytes.NewBuffer(nil)
+	err := p.pipenv.Execute(pexec.Execution{
+		Args:   args,
+		Dir:    workingDir,
+		Env:    pipenvEnv(pipenvLayerPath, sitePackagesPath),
+		Stdout: requirements,
+		Stderr: buffer,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export requirements:\n%s\nerror: %w", tail(buffer.String(), failureTailLines), err)
+	}
+
+	err = os.WriteFile(outputPath, requirements.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write requirements file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package pipenv_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/paketo-buildpacks/pipenv/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPipenvRequirementsProcess(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir      string
+		pipenvLayerPath string
+		outputPath      string
+		executable      *fakes.Executable
+
+		process pipenv.PipenvRequirementsProcess
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+		pipenvLayerPath = t.TempDir()
+		outputPath = filepath.Join(t.TempDir(), "requirements.txt")
+
+		executable = &fakes.Executable{}
+		executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+			_, err := fmt.Fprintln(execution.Stdout, "flask==3.0.0 --hash=sha256:some-hash")
+			Expect(err).NotTo(HaveOccurred())
+			return nil
+		}
+
+		process = pipenv.NewPipenvRequirementsProcess(executable, scribe.NewEmitter(bytes.NewBuffer(nil)))
+	})
+
+	context("Execute", func() {
+		it("writes the exported requirements with hashes to the output path", func() {
+			err := process.Execute(workingDir, pipenvLayerPath, "/some/site-packages", outputPath, false, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"requirements", "--hash"}))
+			Expect(executable.ExecuteCall.Receives.Execution.Dir).To(Equal(workingDir))
+			Expect(executable.ExecuteCall.Receives.Execution.Env).To(ContainElement(ContainSubstring(fmt.Sprintf("PATH=%s", filepath.Join(pipenvLayerPath, "bin")))))
+
+			content, err := os.ReadFile(outputPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("flask==3.0.0 --hash=sha256:some-hash\n"))
+		})
+
+		context("when dev packages and categories are selected", func() {
+			it("passes them to pipenv", func() {
+				err := process.Execute(workingDir, pipenvLayerPath, "/some/site-packages", outputPath, true, []string{"packages", "docs"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"requirements", "--hash", "--dev", "--categories", "packages docs"}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when pipenv requirements fails", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						_, err := fmt.Fprintln(execution.Stderr, "stderr output")
+						Expect(err).NotTo(HaveOccurred())
+						return errors.New("pipenv failed")
+					}
+				})
+
+				it("returns an error", func() {
+					err := process.Execute(workingDir, pipenvLayerPath, "/some/site-packages", outputPath, false, nil)
+					Expect(err).To(MatchError(ContainSubstring("failed to export requirements")))
+					Expect(err).To(MatchError(ContainSubstring("stderr output")))
+					Expect(err).To(MatchError(ContainSubstring("pipenv failed")))
+				})
+			})
+
+			context("when the output file cannot be written", func() {
+				it("returns an error", func() {
+					err := process.Execute(workingDir, pipenvLayerPath, "/some/site-packages", filepath.Join(outputPath, "missing", "requirements.txt"), false, nil)
+					Expect(err).To(MatchError(ContainSubstring("failed to write requirements file")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,309 @@
+package pipenv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf16"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// LockCheckOff disables the Pipfile.lock staleness check.
+	LockCheckOff = "off"
+
+	// LockCheckWarn logs a warning when Pipfile.lock is out of date.
+	LockCheckWarn = "warn"
+
+	// LockCheckFail fails the build when Pipfile.lock is out of date.
+	LockCheckFail = "fail"
+)
+
+// defaultPipfileSource is the source pipenv assumes when a Pipfile does not
+// declare any [[source]].
+var defaultPipfileSource = map[string]interface{}{
+	"name":       "pypi",
+	"url":        "https://pypi.org/simple",
+	"verify_ssl": true,
+}
+
+// pipfileSections are the Pipfile tables that are either hashed under a
+// different key or not hashed at all.
+var pipfileSections = map[string]bool{
+	"source":       true,
+	"packages":     true,
+	"dev-packages": true,
+	"requires":     true,
+	"scripts":      true,
+	"pipfile":      true,
+	"pipenv":       true,
+	"default":      true,
+	"develop":      true,
+}
+
+// CheckPipfileLock compares the hash of the Pipfile in workingDir to the one
+// recorded in its Pipfile.lock. Depending on the mode, a stale lockfile is
+// ignored, logged as a warning, or reported as an error. The check is skipped
+// when either file is missing.
+func CheckPipfileLock(workingDir, mode string, logger scribe.Emitter) error {
+	switch mode {
+	case "", LockCheckOff:
+		return nil
+	case LockCheckWarn, LockCheckFail:
+	default:
+		return fmt.Errorf("invalid Pipfile.lock check mode %q: must be one of %q, %q or %q", mode, LockCheckOff, LockCheckWarn, LockCheckFail)
+	}
+
+	pipfilePath := filepath.Join(workingDir, "Pipfile")
+	lockPath := filepath.Join(workingDir, "Pipfile.lock")
+
+	for _, path := range []string{pipfilePath, lockPath} {
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				logger.Debug.Process("Skipping Pipfile.lock check: %s not found", filepath.Base(path))
+				return nil
+			}
+			return err
+		}
+	}
+
+	logger.Process("Checking Pipfile.lock is up to date")
+
+	expected, err := CalculatePipfileHash(pipfilePath)
+	if err != nil {
+		return err
+	}
+
+	actual, err := readPipfileLockHash(lockPath)
+	if err != nil {
+		return err
+	}
+
+	if actual == expected {
+		logger.Subprocess("Pipfile.lock is up to date (sha256:%s)", expected)
+		logger.Break()
+		return nil
+	}
+
+	message := fmt.Sprintf("Pipfile.lock is out of date: its hash (sha256:%s) does not match the Pipfile (sha256:%s). Run 'pipenv lock' and commit the updated Pipfile.lock", actual, expected)
+	if mode == LockCheckFail {
+		return fmt.Errorf("%s", message)
+	}
+
+	logger.Subprocess("WARNING: %s", message)
+	logger.Break()
+
+	return nil
+}
+
+// CalculatePipfileHash computes the hash pipenv records in Pipfile.lock under
+// _meta.hash.sha256: the sha256 of the Pipfile content, reorganized into
+// lockfile sections and serialized as compact JSON with sorted keys.
+func CalculatePipfileHash(path string) (string, error) {
+	var pipfile map[string]interface{}
+	_, err := toml.DecodeFile(path, &pipfile)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse Pipfile: %w", err)
+	}
+
+	sources, ok := pipfile["source"]
+	if !ok {
+		sources = []map[string]interface{}{defaultPipfileSource}
+	}
+
+	data := map[string]interface{}{
+		"_meta": map[string]interface{}{
+			"sources":  sources,
+			"requires": tableOrEmpty(pipfile["requires"]),
+		},
+		"default": tableOrEmpty(pipfile["packages"]),
+		"develop": tableOrEmpty(pipfile["dev-packages"]),
+	}
+
+	// Custom package categories are hashed under their own names.
+	for category, values := range pipfile {
+		if !pipfileSections[category] {
+			data[category] = values
+		}
+	}
+
+	content := bytes.NewBuffer(nil)
+	err = writePythonJSON(content, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash Pipfile: %w", err)
+	}
+
+	sum := sha256.Sum256(content.Bytes())
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func readPipfileLockHash(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var lockfile struct {
+		Meta struct {
+			Hash struct {
+				SHA256 string `json:"sha256"`
+			} `json:"hash"`
+		} `json:"_meta"`
+	}
+
+	err = json.Unmarshal(content, &lockfile)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse Pipfile.lock: %w", err)
+	}
+
+	if lockfile.Meta.Hash.SHA256 == "" {
+		return "", fmt.Errorf("failed to parse Pipfile.lock: _meta.hash.sha256 is missing")
+	}
+
+	return lockfile.Meta.Hash.SHA256, nil
+}
+
+func tableOrEmpty(value interface{}) interface{} {
+	if value == nil {
+		return map[string]interface{}{}
+	}
+
+	return value
+}
+
+// writePythonJSON serializes the value the way Python's
+// json.dumps(value, sort_keys=True, separators=(",", ":")) does, which
+// (unlike encoding/json) escapes all non-ASCII characters and does not escape
+// HTML characters.
+func writePythonJSON(buffer *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case bool:
+		buffer.WriteString(strconv.FormatBool(v))
+	case int64:
+		buffer.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			buffer.WriteString("Infinity")
+		case math.IsInf(v, -1):
+			buffer.WriteString("-Infinity")
+		case math.IsNaN(v):
+			buffer.WriteString("NaN")
+		case v == math.Trunc(v) && math.Abs(v) < 1e16:
+			buffer.WriteString(strconv.FormatFloat(v, 'f', 1, 64))
+		default:
+			buffer.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	case string:
+		writePythonJSONString(buffer, v)
+	case time.Time:
+		// Python's json module cannot serialize TOML dates, so pipenv would
+		// fail to hash such a Pipfile as well.
+		return fmt.Errorf("unsupported date value %s", v)
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writePythonJSON(buffer, element); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case []map[string]interface{}:
+		buffer.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writePythonJSON(buffer, element); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessCodePoints(keys[i], keys[j])
+		})
+
+		buffer.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writePythonJSONString(buffer, key)
+			buffer.WriteByte(':')
+			if err := writePythonJSON(buffer, v[key]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported value %v (%T)", v, v)
+	}
+
+	return nil
+}
+
+func writePythonJSONString(buffer *bytes.Buffer, s string) {
+	buffer.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		default:
+			switch {
+			case r < 0x20 || (r >= 0x7f && r < 0x10000):
+				fmt.Fprintf(buffer, `\u%04x`, r)
+			case r >= 0x10000:
+				high, low := utf16.EncodeRune(r)
+				fmt.Fprintf(buffer, `\u%04x\u%04x`, high, low)
+			default:
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+}
+
+// lessCodePoints orders strings by Unicode code point, as Python does.
+func lessCodePoints(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	for i := 0; i < len(ra) && i < len(rb); i++ {
+		if ra[i] != rb[i] {
+			return ra[i] < rb[i]
+		}
+	}
+
+	return len(ra) < len(rb)
+}
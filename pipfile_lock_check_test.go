@@ -0,0 +1,165 @@
+package pipenv_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPipfileLockCheck(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		buffer     *bytes.Buffer
+		logger     scribe.Emitter
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte(`[[source]]
+url = "https://pypi.org/simple"
+verify_ssl = true
+name = "pypi"
+
+[packages]
+flask = "*"
+requests = {version = ">=2.0", extras = ["socks"]}
+"café" = "==1.0"
+
+[dev-packages]
+pytest = "<9"
+
+[requires]
+python_version = "3.12"
+
+[scripts]
+web = "gunicorn app:app"
+
+[tests]
+tox = ">=4 <&>"
+`), 0644)).To(Succeed())
+
+		buffer = bytes.NewBuffer(nil)
+		logger = scribe.NewEmitter(buffer)
+	})
+
+	context("CalculatePipfileHash", func() {
+		it("computes the same hash as pipenv", func() {
+			hash, err := pipenv.CalculatePipfileHash(filepath.Join(workingDir, "Pipfile"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).To(Equal("15e17a60b976d7eabe56e0863697eb8c07dd1a14f38e629feaf6d9a9ef97eadd"))
+		})
+
+		context("when the Pipfile has no sources", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte("[packages]\nflask = \"*\"\n"), 0644)).To(Succeed())
+			})
+
+			it("hashes it with the default PyPI source", func() {
+				hash, err := pipenv.CalculatePipfileHash(filepath.Join(workingDir, "Pipfile"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hash).To(Equal("f226f2c246fa4694a5658fd6793e384e9b5ea4b2502a4da2f7d0a795e3efb63f"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the Pipfile is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte("[packages\n"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.CalculatePipfileHash(filepath.Join(workingDir, "Pipfile"))
+					Expect(err).To(MatchError(ContainSubstring("failed to parse Pipfile")))
+				})
+			})
+		})
+	})
+
+	context("CheckPipfileLock", func() {
+		var writeLock = func(hash string) {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte(`{"_meta": {"hash": {"sha256": "`+hash+`"}}}`), 0644)).To(Succeed())
+		}
+
+		context("when the lockfile is up to date", func() {
+			it.Before(func() {
+				writeLock("15e17a60b976d7eabe56e0863697eb8c07dd1a14f38e629feaf6d9a9ef97eadd")
+			})
+
+			it("passes", func() {
+				Expect(pipenv.CheckPipfileLock(workingDir, pipenv.LockCheckFail, logger)).To(Succeed())
+				Expect(buffer.String()).To(ContainSubstring("Pipfile.lock is up to date"))
+			})
+		})
+
+		context("when the lockfile is out of date", func() {
+			it.Before(func() {
+				writeLock("some-stale-hash")
+			})
+
+			it("does nothing when the check is off", func() {
+				Expect(pipenv.CheckPipfileLock(workingDir, pipenv.LockCheckOff, logger)).To(Succeed())
+				Expect(pipenv.CheckPipfileLock(workingDir, "", logger)).To(Succeed())
+				Expect(buffer.String()).To(BeEmpty())
+			})
+
+			it("logs a warning in warn mode", func() {
+				Expect(pipenv.CheckPipfileLock(workingDir, pipenv.LockCheckWarn, logger)).To(Succeed())
+				Expect(buffer.String()).To(ContainSubstring("WARNING: Pipfile.lock is out of date"))
+				Expect(buffer.String()).To(ContainSubstring("Run 'pipenv lock'"))
+			})
+
+			it("returns an error in fail mode", func() {
+				err := pipenv.CheckPipfileLock(workingDir, pipenv.LockCheckFail, logger)
+				Expect(err).To(MatchError(ContainSubstring("Pipfile.lock is out of date: its hash (sha256:some-stale-hash) does not match the Pipfile (sha256:15e17a60b976d7eabe56e0863697eb8c07dd1a14f38e629feaf6d9a9ef97eadd)")))
+				Expect(err).To(MatchError(ContainSubstring("Run 'pipenv lock'")))
+			})
+		})
+
+		context("when there is no lockfile", func() {
+			it("skips the check", func() {
+				Expect(pipenv.CheckPipfileLock(workingDir, pipenv.LockCheckFail, logger)).To(Succeed())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the mode is invalid", func() {
+				it("returns an error", func() {
+					err := pipenv.CheckPipfileLock(workingDir, "sometimes", logger)
+					Expect(err).To(MatchError(`invalid Pipfile.lock check mode "sometimes": must be one of "off", "warn" or "fail"`))
+				})
+			})
+
+			context("when the lockfile has no hash", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte(`{"_meta": {}}`), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := pipenv.CheckPipfileLock(workingDir, pipenv.LockCheckWarn, logger)
+					Expect(err).To(MatchError(ContainSubstring("_meta.hash.sha256 is missing")))
+				})
+			})
+
+			context("when the lockfile is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte(`{"_meta":`), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := pipenv.CheckPipfileLock(workingDir, pipenv.LockCheckWarn, logger)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse Pipfile.lock")))
+				})
+			})
+		})
+	})
+}
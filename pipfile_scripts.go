@@ -0,0 +1,93 @@
+package pipenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// DefaultScriptProcess is the script that becomes the default process, unless
+// $BP_PIPENV_DEFAULT_PROCESS is set.
+const DefaultScriptProcess = "web"
+
+// processType matches the process types that the buildpack specification
+// allows.
+var processType = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// ReadPipfileScripts returns the names of the scripts in the [scripts] table
+// of the Pipfile at path, in sorted order. A missing Pipfile has no scripts.
+func ReadPipfileScripts(path string) ([]string, error) {
+	var pipfile struct {
+		Scripts map[string]interface{} `toml:"scripts"`
+	}
+
+	_, err := toml.DecodeFile(path, &pipfile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to parse Pipfile: %w", err)
+	}
+
+	var scripts []string
+	for name := range pipfile.Scripts {
+		scripts = append(scripts, name)
+	}
+	sort.Strings(scripts)
+
+	return scripts, nil
+}
+
+// ScriptProcesses returns a launch process for each script in the [scripts]
+// table of the app's Pipfile, running it with "pipenv run". Scripts listed in
+// $BP_PIPENV_EXCLUDE_SCRIPTS are left out, as are scripts
+// whose names are not valid process types. The script named by
+// $BP_PIPENV_DEFAULT_PROCESS, or else DefaultScriptProcess when there is one,
+// becomes the default process.
+func ScriptProcesses(workingDir string, config Configuration, logger scribe.Emitter) ([]packit.Process, error) {
+	scripts, err := ReadPipfileScripts(filepath.Join(workingDir, "Pipfile"))
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := config.ExcludeScripts
+
+	defaultProcess, explicitDefault := config.DefaultProcess, config.DefaultProcess != ""
+	if !explicitDefault {
+		defaultProcess = DefaultScriptProcess
+	}
+
+	var processes []packit.Process
+	for _, name := range scripts {
+		if slices.Contains(excluded, name) {
+			continue
+		}
+
+		if !processType.MatchString(name) {
+			logger.Subprocess("Skipping script %q: not a valid process type", name)
+			continue
+		}
+
+		processes = append(processes, packit.Process{
+			Type:    name,
+			Command: "pipenv",
+			Args:    []string{"run", name},
+			Direct:  true,
+			Default: name == defaultProcess,
+		})
+	}
+
+	if explicitDefault && !slices.ContainsFunc(processes, func(p packit.Process) bool { return p.Default }) {
+		return nil, fmt.Errorf("invalid value for $BP_PIPENV_DEFAULT_PROCESS: %q is not a script in the Pipfile, or is excluded", defaultProcess)
+	}
+
+	return processes, nil
+}
@@ -0,0 +1,141 @@
+package pipenv_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPipfileScripts(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		config     pipenv.Configuration
+		buffer     *bytes.Buffer
+		logger     scribe.Emitter
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+		config = pipenv.Configuration{}
+		Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte(`[packages]
+flask = "*"
+
+[scripts]
+web = "gunicorn app:app"
+worker = "celery -A tasks worker"
+test = {call = "tests:main()"}
+"not valid" = "echo nope"
+`), 0644)).To(Succeed())
+
+		buffer = bytes.NewBuffer(nil)
+		logger = scribe.NewEmitter(buffer)
+	})
+
+	context("ReadPipfileScripts", func() {
+		it("returns the script names in order", func() {
+			scripts, err := pipenv.ReadPipfileScripts(filepath.Join(workingDir, "Pipfile"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scripts).To(Equal([]string{"not valid", "test", "web", "worker"}))
+		})
+
+		context("when there is no Pipfile", func() {
+			it("returns no scripts", func() {
+				scripts, err := pipenv.ReadPipfileScripts(filepath.Join(t.TempDir(), "Pipfile"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(scripts).To(BeEmpty())
+			})
+		})
+
+		context("when the Pipfile is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte("[scripts"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := pipenv.ReadPipfileScripts(filepath.Join(workingDir, "Pipfile"))
+				Expect(err).To(MatchError(ContainSubstring("failed to parse Pipfile")))
+			})
+		})
+	})
+
+	context("ScriptProcesses", func() {
+		it("returns a process per script running it with pipenv, with web as the default", func() {
+			processes, err := pipenv.ScriptProcesses(workingDir, config, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(processes).To(Equal([]packit.Process{
+				{Type: "test", Command: "pipenv", Args: []string{"run", "test"}, Direct: true},
+				{Type: "web", Command: "pipenv", Args: []string{"run", "web"}, Direct: true, Default: true},
+				{Type: "worker", Command: "pipenv", Args: []string{"run", "worker"}, Direct: true},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring(`Skipping script "not valid": not a valid process type`))
+		})
+
+		context("when BP_PIPENV_DEFAULT_PROCESS and BP_PIPENV_EXCLUDE_SCRIPTS are set", func() {
+			it.Before(func() {
+				config.DefaultProcess = "worker"
+				config.ExcludeScripts = []string{"test", "not valid"}
+			})
+
+			it("makes that script the default and leaves out the excluded ones", func() {
+				processes, err := pipenv.ScriptProcesses(workingDir, config, logger)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(processes).To(Equal([]packit.Process{
+					{Type: "web", Command: "pipenv", Args: []string{"run", "web"}, Direct: true},
+					{Type: "worker", Command: "pipenv", Args: []string{"run", "worker"}, Direct: true, Default: true},
+				}))
+
+				Expect(buffer.String()).To(BeEmpty())
+			})
+		})
+
+		context("when there is no web script", func() {
+			it.Before(func() {
+				config.ExcludeScripts = []string{"web"}
+			})
+
+			it("has no default process", func() {
+				processes, err := pipenv.ScriptProcesses(workingDir, config, logger)
+				Expect(err).NotTo(HaveOccurred())
+				for _, process := range processes {
+					Expect(process.Default).To(BeFalse())
+				}
+			})
+		})
+
+		context("failure cases", func() {
+			context("when BP_PIPENV_DEFAULT_PROCESS is excluded", func() {
+				it.Before(func() {
+					config.DefaultProcess = "web"
+					config.ExcludeScripts = []string{"web"}
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.ScriptProcesses(workingDir, config, logger)
+					Expect(err).To(MatchError(`invalid value for $BP_PIPENV_DEFAULT_PROCESS: "web" is not a script in the Pipfile, or is excluded`))
+				})
+			})
+
+			context("when the Pipfile is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte("[scripts"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.ScriptProcesses(workingDir, config, logger)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse Pipfile")))
+				})
+			})
+		})
+	})
+}
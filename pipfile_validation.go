@@ -0,0 +1,243 @@
+package pipenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// ValidationOff disables the validation of Pipfile and Pipfile.lock.
+	ValidationOff = "off"
+
+	// ValidationWarn logs the problems found in Pipfile and Pipfile.lock as
+	// warnings.
+	ValidationWarn = "warn"
+
+	// ValidationFail fails the build on syntax errors in Pipfile and
+	// Pipfile.lock, and logs the other problems as warnings.
+	ValidationFail = "fail"
+
+	// ValidationStrict fails the build on all problems found in Pipfile and
+	// Pipfile.lock.
+	ValidationStrict = "strict"
+)
+
+// pipfileNonCategories are the Pipfile tables that do not list packages.
+var pipfileNonCategories = map[string]bool{
+	"source":   true,
+	"requires": true,
+	"scripts":  true,
+	"pipfile":  true,
+	"pipenv":   true,
+}
+
+// ValidatePipfile parses the Pipfile and Pipfile.lock in workingDir, and
+// reports syntax errors with their line and column. In the Pipfile, it also
+// reports packages listed in more than one category, index fields that name
+// no [[source]], and an empty [requires] table. Depending on the mode, which
+// defaults to ValidationFail, problems are ignored, logged as warnings, or
+// reported as an error: ValidationFail only reports syntax errors as an
+// error, and ValidationStrict reports all of them. Missing files are not
+// validated.
+func ValidatePipfile(workingDir, mode string, logger scribe.Emitter) error {
+	switch mode {
+	case "":
+		mode = ValidationFail
+	case ValidationOff:
+		return nil
+	case ValidationWarn, ValidationFail, ValidationStrict:
+	default:
+		return fmt.Errorf("invalid Pipfile validation mode %q: must be one of %q, %q, %q or %q", mode, ValidationOff, ValidationWarn, ValidationFail, ValidationStrict)
+	}
+
+	syntaxErrors, problems, err := validatePipfileTOML(filepath.Join(workingDir, "Pipfile"))
+	if err != nil {
+		return err
+	}
+
+	lockSyntaxErrors, err := validatePipfileLockJSON(filepath.Join(workingDir, "Pipfile.lock"))
+	if err != nil {
+		return err
+	}
+	syntaxErrors = append(syntaxErrors, lockSyntaxErrors...)
+
+	var failures, warnings []string
+	switch mode {
+	case ValidationStrict:
+		failures = append(syntaxErrors, problems...)
+	case ValidationFail:
+		failures, warnings = syntaxErrors, problems
+	default:
+		warnings = append(syntaxErrors, problems...)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("invalid Pipfile:\n  %s", strings.Join(failures, "\n  "))
+	}
+
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	logger.Process("Validating Pipfile")
+	for _, warning := range warnings {
+		logger.Subprocess("WARNING: %s", warning)
+	}
+	logger.Break()
+
+	return nil
+}
+
+// validatePipfileTOML returns the syntax errors and the other problems found
+// in the Pipfile at path.
+func validatePipfileTOML(path string) ([]string, []string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to read Pipfile: %w", err)
+	}
+
+	var pipfile map[string]interface{}
+	metadata, err := toml.Decode(string(content), &pipfile)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			// The parser reports an error at the end of a line on the next
+			// line, so the position is recomputed from the byte offset.
+			line, column := lineAndColumn(content, int64(parseErr.Position.Start)+1)
+			return []string{fmt.Sprintf("Pipfile:%d:%d: %s", line, column, parseErr.Message)}, nil, nil
+		}
+		return []string{fmt.Sprintf("Pipfile: %s", err)}, nil, nil
+	}
+
+	var problems []string
+
+	sources := map[string]bool{}
+	if rawSources, ok := pipfile["source"].([]map[string]interface{}); ok {
+		for _, source := range rawSources {
+			if name, ok := source["name"].(string); ok {
+				sources[name] = true
+			}
+		}
+	}
+	if len(sources) == 0 {
+		sources["pypi"] = true
+	}
+
+	var categories []string
+	for name, value := range pipfile {
+		if _, ok := value.(map[string]interface{}); ok && !pipfileNonCategories[name] {
+			categories = append(categories, name)
+		}
+	}
+	// [packages] and [dev-packages] come first, as in pipenv.
+	rank := map[string]int{"packages": 0, "dev-packages": 1}
+	sort.Slice(categories, func(i, j int) bool {
+		ri, ok := rank[categories[i]]
+		if !ok {
+			ri = len(rank)
+		}
+		rj, ok := rank[categories[j]]
+		if !ok {
+			rj = len(rank)
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return categories[i] < categories[j]
+	})
+
+	seen := map[string]string{}
+	for _, category := range categories {
+		packages := pipfile[category].(map[string]interface{})
+
+		var names []string
+		for name := range packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			normalized := normalizePackageName(name)
+			if other, ok := seen[normalized]; ok {
+				problems = append(problems, fmt.Sprintf("Pipfile: package %q is listed in both [%s] and [%s]", name, other, category))
+			} else {
+				seen[normalized] = category
+			}
+
+			if spec, ok := packages[name].(map[string]interface{}); ok {
+				if index, ok := spec["index"].(string); ok && !sources[index] {
+					problems = append(problems, fmt.Sprintf("Pipfile: package %q in [%s] uses index %q, which is not the name of a [[source]]", name, category, index))
+				}
+			}
+		}
+	}
+
+	if metadata.IsDefined("requires") {
+		if requires, ok := pipfile["requires"].(map[string]interface{}); ok && len(requires) == 0 {
+			problems = append(problems, "Pipfile: [requires] is empty: remove it or set python_version")
+		}
+	}
+
+	return nil, problems, nil
+}
+
+// validatePipfileLockJSON returns the syntax errors found in the Pipfile.lock
+// at path.
+func validatePipfileLockJSON(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read Pipfile.lock: %w", err)
+	}
+
+	var lock map[string]interface{}
+	err = json.Unmarshal(content, &lock)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := lineAndColumn(content, syntaxErr.Offset)
+			return []string{fmt.Sprintf("Pipfile.lock:%d:%d: %s", line, column, syntaxErr)}, nil
+		}
+
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			line, column := lineAndColumn(content, typeErr.Offset)
+			return []string{fmt.Sprintf("Pipfile.lock:%d:%d: must be a JSON object", line, column)}, nil
+		}
+
+		return []string{fmt.Sprintf("Pipfile.lock: %s", err)}, nil
+	}
+
+	return nil, nil
+}
+
+// lineAndColumn returns the 1-based line and column of the byte at offset.
+// encoding/json reports the offset after the byte where an error occurred.
+func lineAndColumn(content []byte, offset int64) (int, int) {
+	if offset > 0 {
+		offset--
+	}
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
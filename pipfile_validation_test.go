@@ -0,0 +1,177 @@
+package pipenv_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPipfileValidation(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		buffer     *bytes.Buffer
+		logger     scribe.Emitter
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte(`[[source]]
+url = "https://pypi.org/simple"
+verify_ssl = true
+name = "pypi"
+
+[[source]]
+url = "https://some-index.example.com/simple"
+verify_ssl = true
+name = "internal"
+
+[packages]
+flask = "*"
+some-lib = {version = "*", index = "internal"}
+
+[dev-packages]
+pytest = "*"
+
+[requires]
+python_version = "3.12"
+`), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte(`{
+    "_meta": {},
+    "default": {}
+}
+`), 0644)).To(Succeed())
+
+		buffer = bytes.NewBuffer(nil)
+		logger = scribe.NewEmitter(buffer)
+	})
+
+	context("ValidatePipfile", func() {
+		it("succeeds for valid files", func() {
+			Expect(pipenv.ValidatePipfile(workingDir, "", logger)).To(Succeed())
+			Expect(buffer.String()).To(BeEmpty())
+		})
+
+		context("when there are no files", func() {
+			it("succeeds", func() {
+				Expect(pipenv.ValidatePipfile(t.TempDir(), "", logger)).To(Succeed())
+			})
+		})
+
+		context("when the Pipfile is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte("[packages]\nflask = \"*\"\nrequests = \n"), 0644)).To(Succeed())
+			})
+
+			it("returns an error with the line and column", func() {
+				err := pipenv.ValidatePipfile(workingDir, "", logger)
+				Expect(err).To(MatchError(ContainSubstring("Pipfile:3:12:")))
+			})
+
+			context("when the mode is warn", func() {
+				it("logs it as a warning", func() {
+					Expect(pipenv.ValidatePipfile(workingDir, pipenv.ValidationWarn, logger)).To(Succeed())
+					Expect(buffer.String()).To(ContainSubstring("WARNING: Pipfile:3:12:"))
+				})
+			})
+		})
+
+		context("when the Pipfile.lock is truncated", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte("{\n    \"_meta\": {},\n    \"default\": {"), 0644)).To(Succeed())
+			})
+
+			it("returns an error with the line and column", func() {
+				err := pipenv.ValidatePipfile(workingDir, "", logger)
+				Expect(err).To(MatchError(ContainSubstring("Pipfile.lock:3:16: unexpected end of JSON input")))
+			})
+		})
+
+		context("when the Pipfile.lock has invalid syntax", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte("{\n    \"_meta\": {},\n    \"default\" {}\n}\n"), 0644)).To(Succeed())
+			})
+
+			it("returns an error with the line and column", func() {
+				err := pipenv.ValidatePipfile(workingDir, "", logger)
+				Expect(err).To(MatchError(ContainSubstring("Pipfile.lock:3:15: invalid character '{' after object key")))
+			})
+		})
+
+		context("when the Pipfile.lock is not an object", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte("[]\n"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				err := pipenv.ValidatePipfile(workingDir, "", logger)
+				Expect(err).To(MatchError(ContainSubstring("Pipfile.lock:1:1: must be a JSON object")))
+			})
+		})
+
+		context("when the Pipfile has semantic problems", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile"), []byte(`[packages]
+Flask = "*"
+some-lib = {version = "*", index = "internal"}
+
+[dev-packages]
+flask = "*"
+
+[requires]
+`), 0644)).To(Succeed())
+			})
+
+			it("logs them as warnings", func() {
+				Expect(pipenv.ValidatePipfile(workingDir, "", logger)).To(Succeed())
+				Expect(buffer.String()).To(ContainSubstring("Validating Pipfile"))
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: Pipfile: package "flask" is listed in both [packages] and [dev-packages]`))
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: Pipfile: package "some-lib" in [packages] uses index "internal", which is not the name of a [[source]]`))
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: Pipfile: [requires] is empty`))
+			})
+
+			context("when the mode is strict", func() {
+				it("returns an error listing them", func() {
+					err := pipenv.ValidatePipfile(workingDir, pipenv.ValidationStrict, logger)
+					Expect(err).To(MatchError(ContainSubstring(`Pipfile: package "flask" is listed in both [packages] and [dev-packages]`)))
+					Expect(err).To(MatchError(ContainSubstring(`Pipfile: package "some-lib" in [packages] uses index "internal", which is not the name of a [[source]]`)))
+					Expect(err).To(MatchError(ContainSubstring(`Pipfile: [requires] is empty`)))
+				})
+			})
+
+			context("when the mode is warn", func() {
+				it("logs them as warnings", func() {
+					Expect(pipenv.ValidatePipfile(workingDir, pipenv.ValidationWarn, logger)).To(Succeed())
+					Expect(buffer.String()).To(ContainSubstring("Validating Pipfile"))
+					Expect(buffer.String()).To(ContainSubstring(`WARNING: Pipfile: package "flask" is listed in both [packages] and [dev-packages]`))
+					Expect(buffer.String()).To(ContainSubstring(`WARNING: Pipfile: [requires] is empty`))
+				})
+			})
+
+			context("when the mode is off", func() {
+				it("ignores them", func() {
+					Expect(pipenv.ValidatePipfile(workingDir, pipenv.ValidationOff, logger)).To(Succeed())
+					Expect(buffer.String()).To(BeEmpty())
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the mode is invalid", func() {
+				it("returns an error", func() {
+					err := pipenv.ValidatePipfile(workingDir, "sometimes", logger)
+					Expect(err).To(MatchError(`invalid Pipfile validation mode "sometimes": must be one of "off", "warn", "fail" or "strict"`))
+				})
+			})
+		})
+	})
+}
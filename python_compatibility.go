@@ -0,0 +1,167 @@
+package pipenv
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// SkippedVersion describes a dependency version that was passed over because
+// its Requires-Python excludes the CPython version.
+type SkippedVersion struct {
+	Version        string `json:"version"`
+	RequiresPython string `json:"requires_python"`
+}
+
+// pythonRequirement is a buildpack.toml dependency with the "requires-python"
+// key written by the dependency retriever, which postal.Dependency does not
+// carry.
+type pythonRequirement struct {
+	postal.Dependency
+	RequiresPython string `toml:"requires-python"`
+}
+
+// readPythonRequirements returns the dependencies with the given id for the
+// stack and target platform from the buildpack.toml at path, newest first by
+// PEP 440 ordering, so that they are the ones PEP440Resolver chooses from.
+// Pre-releases are left out unless allowPrerelease is true. A missing file
+// results in no dependencies.
+func readPythonRequirements(path, id, stack string, allowPrerelease bool) ([]pythonRequirement, error) {
+	var buildpack struct {
+		Metadata struct {
+			DefaultVersions map[string]string   `toml:"default-versions"`
+			Dependencies    []pythonRequirement `toml:"dependencies"`
+		} `toml:"metadata"`
+	}
+
+	_, err := toml.DecodeFile(path, &buildpack)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to parse buildpack.toml: %w", err)
+	}
+
+	// A default version is handled by postal like an explicit request.
+	if buildpack.Metadata.DefaultVersions[id] != "" {
+		return nil, nil
+	}
+
+	var requirements []pythonRequirement
+	for _, dependency := range buildpack.Metadata.Dependencies {
+		if !supportsTarget(dependency.Dependency, id, stack) {
+			continue
+		}
+
+		version, err := pep440.Parse(dependency.Version)
+		if err != nil || (version.IsPreRelease() && !allowPrerelease) {
+			continue
+		}
+
+		requirements = append(requirements, dependency)
+	}
+
+	sort.SliceStable(requirements, func(i, j int) bool {
+		return pep440.MustParse(requirements[i].Version).GreaterThan(pep440.MustParse(requirements[j].Version))
+	})
+
+	return requirements, nil
+}
+
+// selectPythonCompatibleVersion returns the newest of the given dependencies
+// whose Requires-Python includes pythonVersion, along with the newer ones it
+// skipped. Dependencies without a Requires-Python are compatible. The version
+// is empty when none of them is compatible.
+func selectPythonCompatibleVersion(requirements []pythonRequirement, pythonVersion string) (string, []SkippedVersion, error) {
+	python, err := pep440.Parse(pythonVersion)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse CPython version %q: %w", pythonVersion, err)
+	}
+
+	var skipped []SkippedVersion
+	for _, requirement := range requirements {
+		if compatible, err := supportsPython(requirement.RequiresPython, python); err != nil {
+			return "", nil, fmt.Errorf("invalid requires-python for %s %s: %w", requirement.ID, requirement.Version, err)
+		} else if compatible {
+			return requirement.Version, skipped, nil
+		}
+
+		skipped = append(skipped, SkippedVersion{Version: requirement.Version, RequiresPython: requirement.RequiresPython})
+	}
+
+	return "", skipped, nil
+}
+
+func supportsPython(requiresPython string, python pep440.Version) (bool, error) {
+	if requiresPython == "" {
+		return true, nil
+	}
+
+	specifiers, err := pep440.NewSpecifiers(requiresPython)
+	if err != nil {
+		return false, err
+	}
+
+	return specifiers.Check(python), nil
+}
+
+// resolvePythonCompatibleVersion picks the version to resolve when none was
+// requested: the newest dependency in buildpack.toml whose Requires-Python
+// includes the CPython that siteProcess runs. The returned version is
+// unchanged when a version was requested, when no dependency declares a
+// Requires-Python, or when the newest one is compatible anyway.
+func resolvePythonCompatibleVersion(buildpackTOMLPath, id, version, stack string, allowPrerelease bool, siteProcess SitePackageProcess, logger scribe.Emitter) (string, []SkippedVersion, error) {
+	if version != "" && version != "default" {
+		return version, nil, nil
+	}
+
+	requirements, err := readPythonRequirements(buildpackTOMLPath, id, stack, allowPrerelease)
+	if err != nil {
+		return "", nil, err
+	}
+
+	declared := false
+	for _, requirement := range requirements {
+		declared = declared || requirement.RequiresPython != ""
+	}
+
+	if !declared {
+		return version, nil, nil
+	}
+
+	pythonVersion, err := siteProcess.PythonVersion()
+	if err != nil {
+		logger.Subprocess("WARNING: not checking Requires-Python, the CPython version is unknown: %s", err)
+		logger.Break()
+		return version, nil, nil
+	}
+
+	selected, skipped, err := selectPythonCompatibleVersion(requirements, pythonVersion)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(skipped) == 0 {
+		return version, nil, nil
+	}
+
+	for _, s := range skipped {
+		logger.Subprocess("Skipping Pipenv %s: requires Python %s, found CPython %s", s.Version, s.RequiresPython, pythonVersion)
+	}
+
+	if selected == "" {
+		logger.Subprocess("WARNING: no Pipenv version supports CPython %s, selecting the newest version", pythonVersion)
+		logger.Break()
+		return version, skipped, nil
+	}
+
+	logger.Subprocess("Selected Pipenv %s, the newest version compatible with CPython %s", selected, pythonVersion)
+	logger.Break()
+
+	return selected, skipped, nil
+}
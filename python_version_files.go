@@ -0,0 +1,151 @@
+package pipenv
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// pythonVersionPin is a CPython version pinned by a file in the app, as the
+// version constraint to request cpython with.
+type pythonVersionPin struct {
+	Source     string
+	Version    string
+	Constraint string
+}
+
+// pinnedPythonVersion matches the CPython versions that can be pinned: a
+// major version, or a major and minor version, with an optional patch.
+var pinnedPythonVersion = regexp.MustCompile(`^\d+(\.\d+){0,2}$`)
+
+// readPythonVersionPins reads the CPython versions pinned by the
+// .python-version file of pyenv and the runtime.txt file of Heroku-style
+// apps in workingDir, in that order. Versions with fewer than three
+// components become wildcard constraints, such as "3.12.*". Pins that cannot
+// be translated into a CPython version, such as pyenv-virtualenv names,
+// "pypy3.10-7.3.12", "3.12-dev" or a .python-version file that selects
+// several versions, are logged and skipped. An error is returned when the
+// pins disagree.
+func readPythonVersionPins(workingDir string, logger scribe.Emitter) ([]pythonVersionPin, error) {
+	var pins []pythonVersionPin
+
+	versions, err := readPythonVersionFile(filepath.Join(workingDir, ".python-version"))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(versions) > 1:
+		logger.Subprocess("Ignoring .python-version: it selects several versions (%s)", strings.Join(versions, ", "))
+	case len(versions) == 1:
+		pin, ok := newPythonVersionPin(".python-version", versions[0])
+		if !ok {
+			logger.Subprocess("Ignoring python version %q in .python-version: not a CPython version such as \"3.12\" or \"3.12.4\"", versions[0])
+			break
+		}
+		pins = append(pins, pin)
+	}
+
+	runtime, err := readRuntimeTxt(filepath.Join(workingDir, "runtime.txt"))
+	if err != nil {
+		return nil, err
+	}
+	if runtime != "" {
+		version, ok := strings.CutPrefix(runtime, "python-")
+		if !ok {
+			logger.Subprocess("Ignoring runtime %q in runtime.txt: not of the form \"python-3.12.4\"", runtime)
+		} else if pin, ok := newPythonVersionPin("runtime.txt", version); !ok {
+			logger.Subprocess("Ignoring python version %q in runtime.txt: not a CPython version such as \"3.12\" or \"3.12.4\"", version)
+		} else {
+			pins = append(pins, pin)
+		}
+	}
+
+	for i := range pins {
+		for _, other := range pins[i+1:] {
+			if !pythonVersionsOverlap(pins[i].Version, other.Version) {
+				return nil, fmt.Errorf("conflicting python versions: %s pins %q but %s pins %q", pins[i].Source, pins[i].Version, other.Source, other.Version)
+			}
+		}
+	}
+
+	return pins, nil
+}
+
+// newPythonVersionPin returns the pin of a CPython version, or false when the
+// version is not one.
+func newPythonVersionPin(source, version string) (pythonVersionPin, bool) {
+	if !pinnedPythonVersion.MatchString(version) {
+		return pythonVersionPin{}, false
+	}
+
+	constraint := version
+	if strings.Count(version, ".") < 2 {
+		constraint = fmt.Sprintf("%s.*", version)
+	}
+
+	return pythonVersionPin{Source: source, Version: version, Constraint: constraint}, true
+}
+
+// readPythonVersionFile returns the versions in a pyenv .python-version file,
+// one per line or separated by whitespace. The "system" version pins
+// nothing.
+func readPythonVersionFile(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read .python-version: %w", err)
+	}
+
+	var versions []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		versions = append(versions, strings.Fields(line)...)
+	}
+
+	if len(versions) == 1 && versions[0] == "system" {
+		return nil, nil
+	}
+
+	return versions, nil
+}
+
+// readRuntimeTxt returns the runtime in a runtime.txt file, such as
+// "python-3.12.4".
+func readRuntimeTxt(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read runtime.txt: %w", err)
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// pythonVersionsOverlap reports whether one version is a prefix of the other
+// by components, such as "3.12" and "3.12.4", so that both pins can be met.
+func pythonVersionsOverlap(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+
+	return true
+}
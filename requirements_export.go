@@ -0,0 +1,94 @@
+package pipenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// contributeRequirements exports the app's Pipfile.lock as a requirements
+// file with hashes into the requirements layer, and points pip-based
+// buildpacks at it through $BP_PIP_REQUIREMENT. The layer is reused as long as
+// Pipfile.lock and the export options are unchanged.
+func contributeRequirements(
+	context packit.BuildContext,
+	pipenvLayerPath string,
+	config Configuration,
+	requirementsProcess RequirementsExportProcess,
+	siteProcess SitePackageProcess,
+	logger scribe.Emitter,
+	clock chronos.Clock,
+) (packit.Layer, error) {
+	dev, categories := config.RequirementsDev, config.RequirementsCategories
+
+	lockPath := filepath.Join(context.WorkingDir, "Pipfile.lock")
+	if _, err := os.Stat(lockPath); err != nil {
+		return packit.Layer{}, fmt.Errorf("exporting requirements requires a Pipfile.lock: %w", err)
+	}
+
+	lockChecksum, err := fs.NewChecksumCalculator().Sum(lockPath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	requirementsLayer, err := context.Layers.Get(Requirements)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	requirementsPath := filepath.Join(requirementsLayer.Path, "requirements.txt")
+	options := fmt.Sprintf("dev=%t categories=%s", dev, strings.Join(categories, ","))
+
+	cachedChecksum, _ := requirementsLayer.Metadata[LockfileChecksumKey].(string)
+	cachedOptions, _ := requirementsLayer.Metadata["options"].(string)
+	if cachedChecksum == lockChecksum && cachedOptions == options {
+		logger.Process("Reusing cached layer %s", requirementsLayer.Path)
+		requirementsLayer.Launch, requirementsLayer.Build, requirementsLayer.Cache = false, true, true
+
+		return requirementsLayer, nil
+	}
+
+	requirementsLayer, err = requirementsLayer.Reset()
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	requirementsLayer.Launch, requirementsLayer.Build, requirementsLayer.Cache = false, true, true
+
+	sitePackagesPath, err := siteProcess.Execute(pipenvLayerPath)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Process("Exporting Pipfile.lock as a requirements file")
+	logger.Subprocess("Running 'pipenv requirements --hash' (%s)", options)
+
+	duration, err := clock.Measure(func() error {
+		return requirementsProcess.Execute(context.WorkingDir, pipenvLayerPath, strings.TrimRight(sitePackagesPath, "\n"), requirementsPath, dev, categories)
+	})
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	requirementsLayer.Metadata = map[string]interface{}{
+		LockfileChecksumKey: lockChecksum,
+		"options":           options,
+		"requirements_file": requirementsPath,
+	}
+
+	requirementsLayer.BuildEnv.Default("BP_PIP_REQUIREMENT", requirementsPath)
+
+	logger.EnvironmentVariables(requirementsLayer)
+
+	return requirementsLayer, nil
+}
@@ -23,11 +23,13 @@ func main() {
 	logger := scribe.NewEmitter(os.Stdout).WithLevel(os.Getenv("BP_LOG_LEVEL"))
 
 	packit.Run(
-		pipenv.Detect(),
+		pipenv.Detect(logger),
 		pipenv.Build(
-			postal.NewService(cargo.NewTransport()),
-			pipenv.NewPipenvInstallProcess(pexec.NewExecutable("pip")),
+			pipenv.NewPEP440Resolver(postal.NewService(cargo.NewTransport())),
+			pipenv.NewPipenvInstallProcess(pexec.NewExecutable("pip"), pexec.NewExecutable("python"), logger),
 			pipenv.NewSiteProcess(pexec.NewExecutable("python")),
+			pipenv.NewPipenvAppInstallProcess(pexec.NewExecutable("python"), pexec.NewExecutable("pipenv"), logger),
+			pipenv.NewPipenvRequirementsProcess(pexec.NewExecutable("pipenv"), logger),
 			Generator{},
 			logger,
 			chronos.DefaultClock),
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 )
@@ -40,3 +41,22 @@ func (p SiteProcess) Execute(targetLayerPath string) (string, error) {
 
 	return sitePackagesPath.String(), nil
 }
+
+// PythonVersion runs python to report the version of the interpreter, such as
+// "3.12.4".
+func (p SiteProcess) PythonVersion() (string, error) {
+	buffer := bytes.NewBuffer(nil)
+	version := bytes.NewBuffer(nil)
+
+	err := p.executable.Execute(pexec.Execution{
+		Args:   []string{"-c", "import platform; print(platform.python_version())"},
+		Stdout: version,
+		Stderr: buffer,
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to determine python version:\n%s\nerror: %w", buffer.String(), err)
+	}
+
+	return strings.TrimSpace(version.String()), nil
+}
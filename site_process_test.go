@@ -74,4 +74,40 @@ func testSiteProcess(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 	})
+
+	context("PythonVersion", func() {
+		it.Before(func() {
+			executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+				_, err := fmt.Fprintln(execution.Stdout, "3.12.4")
+				return err
+			}
+		})
+
+		it("returns the version of the interpreter", func() {
+			version, err := siteProcess.PythonVersion()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("3.12.4"))
+
+			Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"-c", "import platform; print(platform.python_version())"}))
+		})
+
+		context("failure cases", func() {
+			context("when python fails", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						_, err := fmt.Fprintln(execution.Stderr, "stderr output")
+						Expect(err).NotTo(HaveOccurred())
+						return errors.New("python failed")
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := siteProcess.PythonVersion()
+					Expect(err).To(MatchError(ContainSubstring("failed to determine python version:")))
+					Expect(err).To(MatchError(ContainSubstring("stderr output")))
+					Expect(err).To(MatchError(ContainSubstring("error: python failed")))
+				})
+			})
+		})
+	})
 }
@@ -0,0 +1,246 @@
+package pipenv
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+// PEP440Resolver is a DependencyManager that resolves the dependencies in
+// buildpack.toml with PEP 440 version semantics rather than postal's semver
+// ones, so that post-releases, release candidates and versions with more than
+// three segments can be selected. Bills of materials are generated by the
+// wrapped DependencyManager.
+type PEP440Resolver struct {
+	manager         DependencyManager
+	allowPrerelease bool
+}
+
+// PrereleaseSelector is implemented by DependencyManagers that can be told
+// whether pre-releases may be selected.
+type PrereleaseSelector interface {
+	WithPrerelease(allow bool) DependencyManager
+}
+
+// NewPEP440Resolver returns a PEP440Resolver that generates bills of materials
+// with manager.
+func NewPEP440Resolver(manager DependencyManager) PEP440Resolver {
+	return PEP440Resolver{manager: manager}
+}
+
+// WithPrerelease returns a copy of the resolver that selects pre-releases
+// when allow is true.
+func (r PEP440Resolver) WithPrerelease(allow bool) DependencyManager {
+	r.allowPrerelease = allow
+	return r
+}
+
+// Resolve returns the newest dependency with the given id for the stack and
+// target platform whose version satisfies the PEP 440 version specifier,
+// such as "~=2024.0", "==2023.*" or ">=2023,!=2024.1.0". An empty or
+// "default" version selects the default version in buildpack.toml, or any
+// version. Pre-releases are only selected by a resolver returned by
+// WithPrerelease(true).
+func (r PEP440Resolver) Resolve(path, id, version, stack string) (postal.Dependency, error) {
+	allowPrerelease := r.allowPrerelease
+
+	var buildpack struct {
+		Metadata struct {
+			DefaultVersions map[string]string   `toml:"default-versions"`
+			Dependencies    []postal.Dependency `toml:"dependencies"`
+		} `toml:"metadata"`
+	}
+
+	_, err := toml.DecodeFile(path, &buildpack)
+	if err != nil {
+		return postal.Dependency{}, fmt.Errorf("failed to parse buildpack.toml: %w", err)
+	}
+
+	if version == "" || version == "default" {
+		version = "*"
+		if defaultVersion := buildpack.Metadata.DefaultVersions[id]; defaultVersion != "" {
+			version = defaultVersion
+		}
+	}
+
+	specifiers, err := parseVersionSpecifiers(version, allowPrerelease)
+	if err != nil {
+		return postal.Dependency{}, err
+	}
+
+	type candidate struct {
+		dependency postal.Dependency
+		version    pep440.Version
+	}
+
+	var candidates []candidate
+	var supportedVersions []string
+	for _, dependency := range buildpack.Metadata.Dependencies {
+		if !supportsTarget(dependency, id, stack) {
+			continue
+		}
+
+		v, err := pep440.Parse(dependency.Version)
+		if err != nil {
+			return postal.Dependency{}, fmt.Errorf("failed to parse version %q of %q dependency: %w", dependency.Version, id, err)
+		}
+
+		supportedVersions = append(supportedVersions, dependency.Version)
+
+		if v.IsPreRelease() && !allowPrerelease {
+			continue
+		}
+
+		if specifiers.Check(v) {
+			candidates = append(candidates, candidate{dependency: dependency, version: v})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return postal.Dependency{}, fmt.Errorf("failed to satisfy %q dependency version constraint %q: no compatible versions on %q stack. Supported versions are: [%s]",
+			id, version, stack, strings.Join(supportedVersions, ", "))
+	}
+
+	// As with postal, only one dependency for a version may support the
+	// wildcard stack, or the one to select would be ambiguous.
+	wildcards := map[string]int{}
+	for _, c := range candidates {
+		if stacksInclude(c.dependency.Stacks, "*") {
+			wildcards[c.dependency.Version]++
+			if wildcards[c.dependency.Version] > 1 {
+				return postal.Dependency{}, fmt.Errorf("multiple dependencies support wildcard stack for version: %q", c.dependency.Version)
+			}
+		}
+	}
+
+	// Newest first; for the same version, a dependency for specific stacks
+	// wins over one for the wildcard stack.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if c := candidates[i].version.Compare(candidates[j].version); c != 0 {
+			return c > 0
+		}
+		return !stacksInclude(candidates[i].dependency.Stacks, "*") && stacksInclude(candidates[j].dependency.Stacks, "*")
+	})
+
+	return candidates[0].dependency, nil
+}
+
+// GenerateBillOfMaterials generates bills of materials with the wrapped
+// DependencyManager.
+func (r PEP440Resolver) GenerateBillOfMaterials(dependencies ...postal.Dependency) []packit.BOMEntry {
+	return r.manager.GenerateBillOfMaterials(dependencies...)
+}
+
+// supportsTarget reports whether the dependency has the given id and supports
+// the stack and the target platform of the build, as postal decides it: a
+// dependency that declares neither an OS nor an architecture supports every
+// platform.
+func supportsTarget(dependency postal.Dependency, id, stack string) bool {
+	if dependency.ID != id || !stacksInclude(dependency.Stacks, stack) {
+		return false
+	}
+
+	if dependency.OS == "" && dependency.Arch == "" {
+		return true
+	}
+
+	targetOS, targetArch := os.Getenv("CNB_TARGET_OS"), os.Getenv("CNB_TARGET_ARCH")
+	if targetOS == "" {
+		targetOS = runtime.GOOS
+	}
+	if targetArch == "" {
+		targetArch = runtime.GOARCH
+	}
+
+	return dependency.OS == targetOS && dependency.Arch == targetArch
+}
+
+func stacksInclude(stacks []string, stack string) bool {
+	for _, s := range stacks {
+		if s == stack || s == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// semverConstraint matches a single constraint in the semver forms that
+// postal accepts: an optional "=", "~", "~>" or "^" operator followed by a
+// version of up to three parts, any of which may be an "x", "X" or "*"
+// wildcard.
+var semverConstraint = regexp.MustCompile(`^(=|~>|~|\^)?\s*v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?$`)
+
+// parseVersionSpecifiers parses a PEP 440 version specifier. Constraints
+// written for postal keep their semver meaning: a partial version or one
+// with a wildcard, such as "2024.0" or "2024.x", matches the versions it is a
+// prefix of, "~ X.Y.Z" and "~> X.Y.Z" match X.Y.Z and later patches, and
+// "^X.Y.Z" matches X.Y.Z and later versions with the same major version.
+// Alternatives are separated by "||".
+func parseVersionSpecifiers(version string, allowPrerelease bool) (pep440.Specifiers, error) {
+	version = strings.TrimSpace(version)
+
+	var alternatives []string
+	for _, alternative := range strings.Split(version, "||") {
+		alternative = strings.TrimSpace(alternative)
+		if matches := semverConstraint.FindStringSubmatch(alternative); matches != nil {
+			alternative = translateSemverConstraint(matches[1], matches[2:])
+		}
+		alternatives = append(alternatives, alternative)
+	}
+
+	specifiers, err := pep440.NewSpecifiers(strings.Join(alternatives, " || "), pep440.WithPreRelease(allowPrerelease))
+	if err != nil {
+		return pep440.Specifiers{}, fmt.Errorf("invalid version constraint %q: must be a PEP 440 version specifier such as \"~=2024.0\", \"==2023.*\" or \"!=2024.1.0\"", version)
+	}
+
+	return specifiers, nil
+}
+
+// translateSemverConstraint returns the PEP 440 specifier that matches the
+// same versions as the semver constraint with the given operator and version
+// parts, as read by github.com/Masterminds/semver.
+func translateSemverConstraint(operator string, parts []string) string {
+	// Parts after a wildcard or a missing part are ignored, as they are by
+	// semver.
+	var numbers []string
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, "xX*") {
+			break
+		}
+		numbers = append(numbers, part)
+	}
+
+	if len(numbers) == 0 {
+		return "*"
+	}
+
+	prefix := strings.Join(numbers, ".")
+	switch operator {
+	case "~", "~>":
+		if len(numbers) == 3 {
+			return fmt.Sprintf("~=%s", prefix)
+		}
+	case "^":
+		switch {
+		case len(numbers) == 1 || numbers[0] != "0":
+			return fmt.Sprintf(">=%s,==%s.*", prefix, numbers[0])
+		case len(numbers) == 2 || numbers[1] != "0":
+			return fmt.Sprintf(">=%s,==0.%s.*", prefix, numbers[1])
+		}
+	}
+
+	if len(numbers) == 3 {
+		return fmt.Sprintf("==%s", prefix)
+	}
+
+	return fmt.Sprintf("==%s.*", prefix)
+}
@@ -0,0 +1,256 @@
+package pipenv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/paketo-buildpacks/pipenv/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVersionResolution(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		buildpackTOMLPath string
+		dependencyManager *fakes.DependencyManager
+		resolver          pipenv.PEP440Resolver
+	)
+
+	it.Before(func() {
+		buildpackTOMLPath = filepath.Join(t.TempDir(), "buildpack.toml")
+		Expect(os.WriteFile(buildpackTOMLPath, []byte(`
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2023.12.1"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.0.1"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.0.1.post1"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.1.0"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.1.0"
+  stacks = ["some-stack"]
+  uri = "some-stack-specific-uri"
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.1.0.1"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2025.0.0rc1"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2026.0.0"
+  stacks = ["other-stack"]
+
+[[metadata.dependencies]]
+  id = "other"
+  version = "2027.0.0"
+  stacks = ["*"]
+`), 0600)).To(Succeed())
+
+		dependencyManager = &fakes.DependencyManager{}
+		resolver = pipenv.NewPEP440Resolver(dependencyManager)
+	})
+
+	resolve := func(version string) (string, error) {
+		dependency, err := resolver.Resolve(buildpackTOMLPath, "pipenv", version, "some-stack")
+		return dependency.Version, err
+	}
+
+	context("Resolve", func() {
+		it("selects the newest final release with PEP 440 ordering", func() {
+			Expect(resolve("")).To(Equal("2024.1.0.1"))
+			Expect(resolve("default")).To(Equal("2024.1.0.1"))
+			Expect(resolve("*")).To(Equal("2024.1.0.1"))
+		})
+
+		it("applies PEP 440 version specifiers", func() {
+			Expect(resolve("~=2024.0")).To(Equal("2024.1.0.1"))
+			Expect(resolve("~=2024.0.0")).To(Equal("2024.0.1.post1"))
+			Expect(resolve("==2023.*")).To(Equal("2023.12.1"))
+			Expect(resolve("<2024.1,!=2024.0.1.post1")).To(Equal("2024.0.1"))
+			Expect(resolve("!=2024.1.0.1")).To(Equal("2024.1.0"))
+			Expect(resolve("2024.0.1")).To(Equal("2024.0.1"))
+		})
+
+		it("reads postal's semver constraints with their semver meaning", func() {
+			Expect(resolve("2024")).To(Equal("2024.1.0.1"))
+			Expect(resolve("2024.0")).To(Equal("2024.0.1.post1"))
+			Expect(resolve("=2024.0")).To(Equal("2024.0.1.post1"))
+			Expect(resolve("2024.x")).To(Equal("2024.1.0.1"))
+			Expect(resolve("2024.0.x")).To(Equal("2024.0.1.post1"))
+			Expect(resolve("2023.X.X")).To(Equal("2023.12.1"))
+			Expect(resolve("2023.*")).To(Equal("2023.12.1"))
+			Expect(resolve("x")).To(Equal("2024.1.0.1"))
+			Expect(resolve("~> 2024.0")).To(Equal("2024.0.1.post1"))
+			Expect(resolve("~2024.1")).To(Equal("2024.1.0.1"))
+			Expect(resolve("~2024.0.1")).To(Equal("2024.0.1.post1"))
+			Expect(resolve("~> 2023")).To(Equal("2023.12.1"))
+			Expect(resolve("^2023.1")).To(Equal("2023.12.1"))
+			Expect(resolve("^2024.0.1")).To(Equal("2024.1.0.1"))
+			Expect(resolve("^2024.x")).To(Equal("2024.1.0.1"))
+			Expect(resolve("2023 || 2024.0")).To(Equal("2024.0.1.post1"))
+
+			_, err := resolve("^2023.12.2")
+			Expect(err).To(MatchError(ContainSubstring(`no compatible versions`)))
+		})
+
+		it("prefers a dependency for the specific stack", func() {
+			dependency, err := resolver.Resolve(buildpackTOMLPath, "pipenv", "==2024.1.0", "some-stack")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependency.URI).To(Equal("some-stack-specific-uri"))
+		})
+
+		context("when dependencies are built for specific platforms", func() {
+			it.Before(func() {
+				t.Setenv("CNB_TARGET_OS", "linux")
+				t.Setenv("CNB_TARGET_ARCH", "amd64")
+
+				content, err := os.ReadFile(buildpackTOMLPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(buildpackTOMLPath, append(content, []byte(`
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.2.0"
+  stacks = ["*"]
+  os = "linux"
+  arch = "amd64"
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.3.0"
+  stacks = ["*"]
+  os = "linux"
+  arch = "arm64"
+`)...), 0600)).To(Succeed())
+			})
+
+			it("selects only those for the target platform", func() {
+				Expect(resolve("")).To(Equal("2024.2.0"))
+			})
+		})
+
+		context("when a default version is set", func() {
+			it.Before(func() {
+				content, err := os.ReadFile(buildpackTOMLPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(buildpackTOMLPath, append([]byte("[metadata.default-versions]\n  pipenv = \"~=2023.0\"\n"), content...), 0600)).To(Succeed())
+			})
+
+			it("selects the default version when none is requested", func() {
+				Expect(resolve("")).To(Equal("2023.12.1"))
+				Expect(resolve(">=2024")).To(Equal("2024.1.0.1"))
+			})
+		})
+
+		context("when pre-releases are allowed", func() {
+			it.Before(func() {
+				resolver = resolver.WithPrerelease(true).(pipenv.PEP440Resolver)
+			})
+
+			it("selects pre-releases", func() {
+				Expect(resolve("")).To(Equal("2025.0.0rc1"))
+				Expect(resolve(">=2025.0.0rc1")).To(Equal("2025.0.0rc1"))
+			})
+		})
+
+		context("when a pre-release is requested without opting in", func() {
+			it("returns an error", func() {
+				_, err := resolve("==2025.0.0rc1")
+				Expect(err).To(MatchError(`failed to satisfy "pipenv" dependency version constraint "==2025.0.0rc1": no compatible versions on "some-stack" stack. Supported versions are: [2023.12.1, 2024.0.1, 2024.0.1.post1, 2024.1.0, 2024.1.0, 2024.1.0.1, 2025.0.0rc1]`))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the constraint is not a PEP 440 specifier", func() {
+				it("returns an error", func() {
+					_, err := resolve("2024.0.1.1.x")
+					Expect(err).To(MatchError(ContainSubstring(`invalid version constraint "2024.0.1.1.x": must be a PEP 440 version specifier`)))
+				})
+			})
+
+			context("when a version in buildpack.toml is not a PEP 440 version", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(buildpackTOMLPath, []byte(`
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "not-a-version"
+  stacks = ["*"]
+`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := resolve("")
+					Expect(err).To(MatchError(ContainSubstring(`failed to parse version "not-a-version" of "pipenv" dependency`)))
+				})
+			})
+
+			context("when multiple dependencies for a version support the wildcard stack", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(buildpackTOMLPath, []byte(`
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.1.0"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "pipenv"
+  version = "2024.1.0"
+  stacks = ["some-stack", "*"]
+`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := resolve("")
+					Expect(err).To(MatchError(`multiple dependencies support wildcard stack for version: "2024.1.0"`))
+				})
+			})
+
+			context("when buildpack.toml cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(buildpackTOMLPath, []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := resolve("")
+					Expect(err).To(MatchError(ContainSubstring("failed to parse buildpack.toml")))
+				})
+			})
+		})
+	})
+
+	context("GenerateBillOfMaterials", func() {
+		it("delegates to the wrapped dependency manager", func() {
+			dependencyManager.GenerateBillOfMaterialsCall.Returns.BOMEntrySlice = []packit.BOMEntry{{Name: "pipenv"}}
+
+			entries := resolver.GenerateBillOfMaterials(postal.Dependency{ID: "pipenv"})
+			Expect(entries).To(Equal([]packit.BOMEntry{{Name: "pipenv"}}))
+			Expect(dependencyManager.GenerateBillOfMaterialsCall.Receives.Dependencies).To(Equal([]postal.Dependency{{ID: "pipenv"}}))
+		})
+	})
+}
@@ -0,0 +1,701 @@
+package pipenv
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+)
+
+const (
+	// VulnerabilityDatabaseBindingType is the service binding type under which
+	// an OSV vulnerability database can be supplied.
+	VulnerabilityDatabaseBindingType = "osv"
+
+	// DefaultVulnerabilityThreshold is the lowest severity that fails the build
+	// when $BP_PIPENV_VULNERABILITY_THRESHOLD is not set.
+	DefaultVulnerabilityThreshold = "high"
+
+	// VulnerabilitySourceInstalled marks findings for distributions installed
+	// into the pipenv layer.
+	VulnerabilitySourceInstalled = "installed"
+
+	// VulnerabilitySourceLockfile marks findings for Pipfile.lock entries.
+	VulnerabilitySourceLockfile = "Pipfile.lock"
+)
+
+// severityRanks orders the severities findings are reported with. Findings
+// whose severity cannot be determined are UNKNOWN.
+var severityRanks = map[string]int{
+	"UNKNOWN":  0,
+	"LOW":      1,
+	"MEDIUM":   2,
+	"HIGH":     3,
+	"CRITICAL": 4,
+}
+
+// thresholdRanks maps the accepted $BP_PIPENV_VULNERABILITY_THRESHOLD values
+// to the lowest severity rank that fails the build.
+var thresholdRanks = map[string]int{
+	"any":      0,
+	"low":      1,
+	"medium":   2,
+	"high":     3,
+	"critical": 4,
+}
+
+// VulnerabilityFinding is a known vulnerability affecting a package version.
+type VulnerabilityFinding struct {
+	ID            string   `json:"id"`
+	Aliases       []string `json:"aliases,omitempty"`
+	Package       string   `json:"package"`
+	Version       string   `json:"version"`
+	Source        string   `json:"source"`
+	Severity      string   `json:"severity"`
+	FixedVersions []string `json:"fixed_versions,omitempty"`
+	Summary       string   `json:"summary,omitempty"`
+}
+
+// VulnerabilityScanner matches packages against an offline OSV vulnerability
+// database and fails the build on findings at or above a severity threshold.
+type VulnerabilityScanner struct {
+	database     VulnerabilityDatabase
+	threshold    string
+	scanLockfile bool
+}
+
+// NewVulnerabilityScanner configures a scanner from the configuration. The
+// database is read from the "osv" service binding and from the file or
+// directory at $BP_PIPENV_VULNERABILITY_DB. Without either, the scanner is
+// disabled.
+func NewVulnerabilityScanner(platformPath string, config Configuration) (VulnerabilityScanner, error) {
+	scanner := VulnerabilityScanner{
+		threshold:    DefaultVulnerabilityThreshold,
+		scanLockfile: config.VulnerabilityScanLockfile,
+	}
+
+	if value := config.VulnerabilityThreshold; value != "" {
+		scanner.threshold = strings.ToLower(value)
+		if _, ok := thresholdRanks[scanner.threshold]; !ok {
+			return VulnerabilityScanner{}, fmt.Errorf("invalid value for $BP_PIPENV_VULNERABILITY_THRESHOLD: %q must be one of \"any\", \"low\", \"medium\", \"high\" or \"critical\"", value)
+		}
+	}
+
+	bindings, err := servicebindings.NewResolver().Resolve(VulnerabilityDatabaseBindingType, "", platformPath)
+	if err != nil {
+		return VulnerabilityScanner{}, fmt.Errorf("failed to resolve vulnerability database binding: %w", err)
+	}
+
+	for _, binding := range bindings {
+		err = scanner.database.loadBinding(binding)
+		if err != nil {
+			return VulnerabilityScanner{}, err
+		}
+	}
+
+	if path := config.VulnerabilityDB; path != "" {
+		err = scanner.database.loadPath(path)
+		if err != nil {
+			return VulnerabilityScanner{}, err
+		}
+	}
+
+	return scanner, nil
+}
+
+// Enabled reports whether a vulnerability database was supplied.
+func (s VulnerabilityScanner) Enabled() bool {
+	return s.database.advisories != nil
+}
+
+// Scan returns the findings for the given installed packages and, when
+// $BP_PIPENV_VULNERABILITY_SCAN_LOCKFILE is true, for the entries of the
+// Pipfile.lock in workingDir.
+func (s VulnerabilityScanner) Scan(workingDir string, packages []InstalledPackage, logger scribe.Emitter) ([]VulnerabilityFinding, error) {
+	if !s.Enabled() {
+		logger.Debug.Process("Skipping vulnerability scan: no vulnerability database supplied")
+		return nil, nil
+	}
+
+	// A nil list means that the installed packages are unknown, which must
+	// not pass as a clean scan.
+	if packages == nil {
+		return nil, fmt.Errorf("failed to scan for known vulnerabilities: the packages installed into the pipenv layer are unknown")
+	}
+
+	logger.Process("Scanning for known vulnerabilities")
+	logger.Subprocess("Loaded %d advisories", s.database.count)
+
+	findings := []VulnerabilityFinding{}
+	for _, pkg := range packages {
+		findings = append(findings, s.database.Match(pkg.Name, pkg.Version, VulnerabilitySourceInstalled)...)
+	}
+
+	if s.scanLockfile {
+		entries, err := readPipfileLockVersions(filepath.Join(workingDir, "Pipfile.lock"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			findings = append(findings, s.database.Match(entry.Name, entry.Version, VulnerabilitySourceLockfile)...)
+		}
+	}
+
+	if len(findings) == 0 {
+		logger.Subprocess("No known vulnerabilities found")
+	}
+
+	for _, finding := range findings {
+		logger.Subprocess("%s %s (%s): %s [%s]", finding.Package, finding.Version, finding.Source, finding.ID, finding.Severity)
+	}
+	logger.Break()
+
+	return findings, nil
+}
+
+// Check returns an error when any of the findings is at or above the
+// configured severity threshold.
+func (s VulnerabilityScanner) Check(findings []VulnerabilityFinding) error {
+	threshold := thresholdRanks[s.threshold]
+
+	var failing []string
+	for _, finding := range findings {
+		if severityRanks[finding.Severity] >= threshold {
+			failing = append(failing, fmt.Sprintf("%s %s: %s (%s)", finding.Package, finding.Version, finding.ID, finding.Severity))
+		}
+	}
+
+	if len(failing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("found %d known vulnerabilities at or above the %q severity threshold:\n  %s", len(failing), s.threshold, strings.Join(failing, "\n  "))
+}
+
+// VulnerabilityDatabase holds OSV advisories for the PyPI ecosystem, keyed by
+// normalized package name.
+type VulnerabilityDatabase struct {
+	advisories map[string][]osvAdvisory
+	loaded     map[string]bool
+	count      int
+}
+
+type osvAdvisory struct {
+	ID        string        `json:"id"`
+	Aliases   []string      `json:"aliases"`
+	Summary   string        `json:"summary"`
+	Withdrawn string        `json:"withdrawn"`
+	Severity  []osvSeverity `json:"severity"`
+	Affected  []osvAffected `json:"affected"`
+
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []osvRange `json:"ranges"`
+	Versions []string   `json:"versions"`
+}
+
+type osvRange struct {
+	Type   string `json:"type"`
+	Events []struct {
+		Introduced   string `json:"introduced"`
+		Fixed        string `json:"fixed"`
+		LastAffected string `json:"last_affected"`
+	} `json:"events"`
+}
+
+// LoadVulnerabilityDatabase reads the OSV advisories in the file or directory
+// at path. JSON files may hold a single advisory or an array of them, and zip
+// archives (as published by osv.dev) are read as well.
+func LoadVulnerabilityDatabase(path string) (VulnerabilityDatabase, error) {
+	var database VulnerabilityDatabase
+	err := database.loadPath(path)
+	if err != nil {
+		return VulnerabilityDatabase{}, err
+	}
+
+	return database, nil
+}
+
+func (d *VulnerabilityDatabase) loadPath(root string) error {
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Files inside a directory are only read when they look like part of
+		// the database, but an explicitly given file is always read.
+		if entry.IsDir() || (path != root && !isOSVFile(path)) {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return d.load(path, content)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load vulnerability database: %w", err)
+	}
+
+	d.init()
+
+	return nil
+}
+
+func (d *VulnerabilityDatabase) loadBinding(binding servicebindings.Binding) error {
+	names := make([]string, 0, len(binding.Entries))
+	for name := range binding.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !isOSVFile(name) {
+			continue
+		}
+
+		content, err := binding.Entries[name].ReadBytes()
+		if err != nil {
+			return fmt.Errorf("failed to load vulnerability database from binding %q: %w", binding.Name, err)
+		}
+
+		err = d.load(name, content)
+		if err != nil {
+			return fmt.Errorf("failed to load vulnerability database from binding %q: %w", binding.Name, err)
+		}
+	}
+
+	d.init()
+
+	return nil
+}
+
+// init marks the database as supplied even when it holds no advisories.
+func (d *VulnerabilityDatabase) init() {
+	if d.advisories == nil {
+		d.advisories = map[string][]osvAdvisory{}
+		d.loaded = map[string]bool{}
+	}
+}
+
+func isOSVFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".zip":
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *VulnerabilityDatabase) load(name string, content []byte) error {
+	if strings.EqualFold(filepath.Ext(name), ".zip") {
+		archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		for _, file := range archive.File {
+			if file.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(file.Name), ".json") {
+				continue
+			}
+
+			reader, err := file.Open()
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+
+			content, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+
+			err = d.load(fmt.Sprintf("%s/%s", name, file.Name), content)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var advisories []osvAdvisory
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &advisories)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	} else {
+		var advisory osvAdvisory
+		err := json.Unmarshal(trimmed, &advisory)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		advisories = append(advisories, advisory)
+	}
+
+	d.init()
+	for _, advisory := range advisories {
+		// The same advisory may be supplied by both a binding and a path.
+		if advisory.ID == "" || advisory.Withdrawn != "" || d.loaded[advisory.ID] {
+			continue
+		}
+
+		names := map[string]bool{}
+		for _, affected := range advisory.Affected {
+			if affected.Package.Ecosystem == "PyPI" {
+				names[normalizePackageName(affected.Package.Name)] = true
+			}
+		}
+
+		for name := range names {
+			d.advisories[name] = append(d.advisories[name], advisory)
+		}
+
+		if len(names) > 0 {
+			d.loaded[advisory.ID] = true
+			d.count++
+		}
+	}
+
+	return nil
+}
+
+// Match returns a finding for every advisory affecting the given version of
+// the package.
+func (d VulnerabilityDatabase) Match(name, version, source string) []VulnerabilityFinding {
+	normalized := normalizePackageName(name)
+
+	var findings []VulnerabilityFinding
+	for _, advisory := range d.advisories[normalized] {
+		affected, fixed := advisory.affects(normalized, version)
+		if !affected {
+			continue
+		}
+
+		findings = append(findings, VulnerabilityFinding{
+			ID:            advisory.ID,
+			Aliases:       advisory.Aliases,
+			Package:       name,
+			Version:       version,
+			Source:        source,
+			Severity:      advisory.severity(),
+			FixedVersions: fixed,
+			Summary:       advisory.Summary,
+		})
+	}
+
+	return findings
+}
+
+// affects reports whether the advisory affects the given version of the
+// package, along with the versions that fix it.
+func (a osvAdvisory) affects(name, version string) (bool, []string) {
+	parsed, err := pep440.Parse(version)
+
+	var (
+		affected bool
+		fixed    []string
+	)
+
+	for _, entry := range a.Affected {
+		if entry.Package.Ecosystem != "PyPI" || normalizePackageName(entry.Package.Name) != name {
+			continue
+		}
+
+		for _, v := range entry.Versions {
+			if v == version {
+				affected = true
+			}
+		}
+
+		if err != nil {
+			continue
+		}
+
+		for _, r := range entry.Ranges {
+			if r.Type != "ECOSYSTEM" && r.Type != "SEMVER" {
+				continue
+			}
+
+			inRange, rangeFixed := r.contains(parsed)
+			if inRange {
+				affected = true
+				fixed = append(fixed, rangeFixed...)
+			}
+		}
+	}
+
+	return affected, fixed
+}
+
+// contains evaluates the range events in version order, as described by the
+// OSV schema: a version is affected once it reaches an "introduced" event and
+// until it reaches a "fixed" event or passes a "last_affected" event.
+func (r osvRange) contains(version pep440.Version) (bool, []string) {
+	type event struct {
+		kind    string
+		value   string
+		version pep440.Version
+	}
+
+	var events []event
+	for _, e := range r.Events {
+		for _, candidate := range []event{{kind: "introduced", value: e.Introduced}, {kind: "fixed", value: e.Fixed}, {kind: "last_affected", value: e.LastAffected}} {
+			kind, value := candidate.kind, candidate.value
+			if value == "" {
+				continue
+			}
+
+			if kind == "introduced" && value == "0" {
+				events = append(events, event{kind: kind, value: value, version: pep440.MustParse("0")})
+				continue
+			}
+
+			parsed, err := pep440.Parse(value)
+			if err != nil {
+				continue
+			}
+			events = append(events, event{kind: kind, value: value, version: parsed})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].version.LessThan(events[j].version)
+	})
+
+	var affected bool
+	for _, e := range events {
+		switch e.kind {
+		case "introduced":
+			if version.GreaterThanOrEqual(e.version) {
+				affected = true
+			}
+		case "fixed":
+			if affected && version.LessThan(e.version) {
+				return true, []string{e.value}
+			}
+			affected = false
+		case "last_affected":
+			if affected && version.LessThanOrEqual(e.version) {
+				return true, nil
+			}
+			affected = false
+		}
+	}
+
+	return affected, nil
+}
+
+// severity returns the advisory severity from the GitHub advisory database
+// field, falling back to the CVSS v3 base score.
+func (a osvAdvisory) severity() string {
+	switch strings.ToUpper(a.DatabaseSpecific.Severity) {
+	case "LOW":
+		return "LOW"
+	case "MODERATE", "MEDIUM":
+		return "MEDIUM"
+	case "HIGH":
+		return "HIGH"
+	case "CRITICAL":
+		return "CRITICAL"
+	}
+
+	for _, severity := range a.Severity {
+		if severity.Type != "CVSS_V3" {
+			continue
+		}
+
+		score, ok := cvss3BaseScore(severity.Score)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case score >= 9.0:
+			return "CRITICAL"
+		case score >= 7.0:
+			return "HIGH"
+		case score >= 4.0:
+			return "MEDIUM"
+		case score > 0:
+			return "LOW"
+		}
+	}
+
+	return "UNKNOWN"
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H".
+func cvss3BaseScore(vector string) (float64, bool) {
+	metrics := map[string]string{}
+	for _, part := range strings.Split(vector, "/") {
+		key, value, found := strings.Cut(part, ":")
+		if found {
+			metrics[key] = value
+		}
+	}
+
+	weights := map[string]map[string]float64{
+		"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+		"AC": {"L": 0.77, "H": 0.44},
+		"UI": {"N": 0.85, "R": 0.62},
+		"C":  {"H": 0.56, "L": 0.22, "N": 0},
+		"I":  {"H": 0.56, "L": 0.22, "N": 0},
+		"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	}
+
+	values := map[string]float64{}
+	for metric, options := range weights {
+		value, ok := options[metrics[metric]]
+		if !ok {
+			return 0, false
+		}
+		values[metric] = value
+	}
+
+	changed := metrics["S"] == "C"
+	if !changed && metrics["S"] != "U" {
+		return 0, false
+	}
+
+	privileges := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	if changed {
+		privileges = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}
+	}
+
+	pr, ok := privileges[metrics["PR"]]
+	if !ok {
+		return 0, false
+	}
+
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+
+	if impact <= 0 {
+		return 0, true
+	}
+
+	exploitability := 8.22 * values["AV"] * values["AC"] * pr * values["UI"]
+
+	score := impact + exploitability
+	if changed {
+		score *= 1.08
+	}
+
+	return roundUpCVSS(math.Min(score, 10)), true
+}
+
+// roundUpCVSS rounds up to one decimal place as defined in appendix A of the
+// CVSS v3.1 specification.
+func roundUpCVSS(value float64) float64 {
+	scaled := int64(math.Round(value * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+
+	return (math.Floor(float64(scaled)/10000) + 1) / 10
+}
+
+var packageNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizePackageName normalizes a distribution name as described in PEP 503.
+func normalizePackageName(name string) string {
+	return packageNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// readPipfileLockVersions returns the pinned packages of every section of the
+// Pipfile.lock at path. Entries without a pinned version, such as VCS or path
+// dependencies, are skipped, as is a missing lockfile.
+func readPipfileLockVersions(path string) ([]InstalledPackage, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var lockfile map[string]json.RawMessage
+	err = json.Unmarshal(content, &lockfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Pipfile.lock: %w", err)
+	}
+
+	sections := make([]string, 0, len(lockfile))
+	for section := range lockfile {
+		if section != "_meta" {
+			sections = append(sections, section)
+		}
+	}
+	sort.Strings(sections)
+
+	seen := map[string]bool{}
+	var packages []InstalledPackage
+	for _, section := range sections {
+		var entries map[string]struct {
+			Version string `json:"version"`
+		}
+		err = json.Unmarshal(lockfile[section], &entries)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Pipfile.lock: section %q: %w", section, err)
+		}
+
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			entry := entries[name]
+			version := strings.TrimPrefix(entry.Version, "==")
+			if version == "" || version == entry.Version {
+				continue
+			}
+
+			key := fmt.Sprintf("%s==%s", normalizePackageName(name), version)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			packages = append(packages, InstalledPackage{Name: name, Version: version})
+		}
+	}
+
+	return packages, nil
+}
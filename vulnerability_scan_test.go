@@ -0,0 +1,343 @@
+package pipenv_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/pipenv"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVulnerabilityScan(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		databaseDir string
+	)
+
+	it.Before(func() {
+		databaseDir = t.TempDir()
+
+		Expect(os.WriteFile(filepath.Join(databaseDir, "GHSA-range.json"), []byte(`{
+			"id": "GHSA-range",
+			"aliases": ["CVE-2026-0001"],
+			"summary": "some range advisory",
+			"affected": [{
+				"package": {"ecosystem": "PyPI", "name": "Some_Package"},
+				"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "1.0"}, {"fixed": "1.4.2"}, {"introduced": "2.0"}, {"fixed": "2.1"}]}]
+			}],
+			"database_specific": {"severity": "MODERATE"}
+		}`), 0644)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(databaseDir, "advisories.json"), []byte(`[
+			{
+				"id": "PYSEC-last-affected",
+				"affected": [{
+					"package": {"ecosystem": "PyPI", "name": "other-package"},
+					"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"last_affected": "3.0.0"}]}]
+				}],
+				"severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}]
+			},
+			{
+				"id": "PYSEC-versions",
+				"affected": [{
+					"package": {"ecosystem": "PyPI", "name": "other-package"},
+					"versions": ["4.0.0"]
+				}],
+				"severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N"}]
+			},
+			{
+				"id": "PYSEC-withdrawn",
+				"withdrawn": "2026-01-01T00:00:00Z",
+				"affected": [{
+					"package": {"ecosystem": "PyPI", "name": "other-package"},
+					"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}]}]
+				}]
+			},
+			{
+				"id": "GO-other-ecosystem",
+				"affected": [{
+					"package": {"ecosystem": "Go", "name": "other-package"},
+					"ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}]}]
+				}]
+			}
+		]`), 0644)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(databaseDir, "README.md"), []byte("not an advisory"), 0644)).To(Succeed())
+	})
+
+	context("LoadVulnerabilityDatabase", func() {
+		it("matches versions within the affected ranges", func() {
+			database, err := pipenv.LoadVulnerabilityDatabase(databaseDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(database.Match("some-package", "1.4.1", "installed")).To(Equal([]pipenv.VulnerabilityFinding{
+				{
+					ID:            "GHSA-range",
+					Aliases:       []string{"CVE-2026-0001"},
+					Package:       "some-package",
+					Version:       "1.4.1",
+					Source:        "installed",
+					Severity:      "MEDIUM",
+					FixedVersions: []string{"1.4.2"},
+					Summary:       "some range advisory",
+				},
+			}))
+			Expect(database.Match("some.package", "2.0.1", "installed")).To(HaveLen(1))
+			Expect(database.Match("some-package", "0.9", "installed")).To(BeEmpty())
+			Expect(database.Match("some-package", "1.4.2", "installed")).To(BeEmpty())
+			Expect(database.Match("some-package", "2.1", "installed")).To(BeEmpty())
+		})
+
+		it("matches last affected and explicit versions, with severities from CVSS scores", func() {
+			database, err := pipenv.LoadVulnerabilityDatabase(databaseDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			findings := database.Match("other-package", "3.0.0", "Pipfile.lock")
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].ID).To(Equal("PYSEC-last-affected"))
+			Expect(findings[0].Severity).To(Equal("CRITICAL"))
+			Expect(findings[0].FixedVersions).To(BeEmpty())
+
+			findings = database.Match("other-package", "4.0.0", "Pipfile.lock")
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].ID).To(Equal("PYSEC-versions"))
+			Expect(findings[0].Severity).To(Equal("MEDIUM"))
+
+			Expect(database.Match("other-package", "3.0.1", "Pipfile.lock")).To(BeEmpty())
+		})
+
+		context("when the database is a zip archive", func() {
+			var archivePath string
+
+			it.Before(func() {
+				buffer := bytes.NewBuffer(nil)
+				writer := zip.NewWriter(buffer)
+
+				file, err := writer.Create("GHSA-zipped.json")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = file.Write([]byte(`{"id": "GHSA-zipped", "affected": [{"package": {"ecosystem": "PyPI", "name": "zipped"}, "versions": ["1.0"]}]}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(writer.Close()).To(Succeed())
+
+				archivePath = filepath.Join(t.TempDir(), "all.zip")
+				Expect(os.WriteFile(archivePath, buffer.Bytes(), 0644)).To(Succeed())
+			})
+
+			it("reads the advisories in the archive", func() {
+				database, err := pipenv.LoadVulnerabilityDatabase(archivePath)
+				Expect(err).NotTo(HaveOccurred())
+
+				findings := database.Match("zipped", "1.0", "installed")
+				Expect(findings).To(HaveLen(1))
+				Expect(findings[0].Severity).To(Equal("UNKNOWN"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when an advisory is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(databaseDir, "broken.json"), []byte("{"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.LoadVulnerabilityDatabase(databaseDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to load vulnerability database")))
+					Expect(err).To(MatchError(ContainSubstring("broken.json")))
+				})
+			})
+
+			context("when the database does not exist", func() {
+				it("returns an error", func() {
+					_, err := pipenv.LoadVulnerabilityDatabase(filepath.Join(databaseDir, "missing"))
+					Expect(err).To(MatchError(ContainSubstring("failed to load vulnerability database")))
+				})
+			})
+		})
+	})
+
+	context("VulnerabilityScanner", func() {
+		var (
+			platformDir string
+			workingDir  string
+			config      pipenv.Configuration
+			buffer      *bytes.Buffer
+			logger      scribe.Emitter
+			packages    []pipenv.InstalledPackage
+		)
+
+		it.Before(func() {
+			platformDir = t.TempDir()
+			workingDir = t.TempDir()
+			config = pipenv.Configuration{}
+
+			buffer = bytes.NewBuffer(nil)
+			logger = scribe.NewEmitter(buffer)
+
+			packages = []pipenv.InstalledPackage{
+				{Name: "Some-Package", Version: "1.2.0"},
+				{Name: "safe-package", Version: "1.0.0"},
+			}
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte(`{
+				"_meta": {"hash": {"sha256": "some-hash"}},
+				"default": {
+					"other-package": {"version": "==2.0.0"},
+					"some-vcs-package": {"git": "https://example.com/some-vcs-package.git"}
+				},
+				"develop": {
+					"other_package": {"version": "==2.0.0"}
+				}
+			}`), 0644)).To(Succeed())
+		})
+
+		context("when no database is supplied", func() {
+			it("skips the scan", func() {
+				scanner, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(scanner.Enabled()).To(BeFalse())
+
+				findings, err := scanner.Scan(workingDir, packages, logger)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(findings).To(BeNil())
+				Expect(buffer.String()).To(BeEmpty())
+			})
+		})
+
+		context("when the database is supplied through a service binding", func() {
+			it.Before(func() {
+				bindingDir := filepath.Join(platformDir, "bindings", "some-binding")
+				Expect(os.MkdirAll(bindingDir, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(bindingDir, "type"), []byte("osv"), 0644)).To(Succeed())
+
+				content, err := os.ReadFile(filepath.Join(databaseDir, "GHSA-range.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(bindingDir, "GHSA-range.json"), content, 0644)).To(Succeed())
+			})
+
+			it("scans the installed packages", func() {
+				scanner, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(scanner.Enabled()).To(BeTrue())
+
+				findings, err := scanner.Scan(workingDir, packages, logger)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(findings).To(HaveLen(1))
+				Expect(findings[0].Package).To(Equal("Some-Package"))
+				Expect(findings[0].Source).To(Equal("installed"))
+
+				Expect(buffer.String()).To(ContainSubstring("Loaded 1 advisories"))
+				Expect(buffer.String()).To(ContainSubstring("Some-Package 1.2.0 (installed): GHSA-range [MEDIUM]"))
+			})
+		})
+
+		context("when BP_PIPENV_VULNERABILITY_SCAN_LOCKFILE is true", func() {
+			it.Before(func() {
+				config.VulnerabilityDB = databaseDir
+				config.VulnerabilityScanLockfile = true
+			})
+
+			it("also scans the pinned Pipfile.lock entries once each", func() {
+				scanner, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+				Expect(err).NotTo(HaveOccurred())
+
+				findings, err := scanner.Scan(workingDir, packages, logger)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(findings).To(HaveLen(2))
+				Expect(findings[1].ID).To(Equal("PYSEC-last-affected"))
+				Expect(findings[1].Package).To(Equal("other-package"))
+				Expect(findings[1].Source).To(Equal("Pipfile.lock"))
+			})
+		})
+
+		context("Check", func() {
+			var findings []pipenv.VulnerabilityFinding
+
+			it.Before(func() {
+				findings = []pipenv.VulnerabilityFinding{
+					{ID: "some-id", Package: "some-package", Version: "1.0", Severity: "MEDIUM"},
+					{ID: "other-id", Package: "other-package", Version: "2.0", Severity: "UNKNOWN"},
+				}
+			})
+
+			it("ignores findings below the default threshold", func() {
+				scanner, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(scanner.Check(findings)).To(Succeed())
+			})
+
+			context("when the threshold is lowered", func() {
+				it.Before(func() {
+					config.VulnerabilityThreshold = "MEDIUM"
+				})
+
+				it("fails on the findings at or above it", func() {
+					scanner, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(scanner.Check(findings)).To(MatchError("found 1 known vulnerabilities at or above the \"medium\" severity threshold:\n  some-package 1.0: some-id (MEDIUM)"))
+				})
+			})
+
+			context("when the threshold is any", func() {
+				it.Before(func() {
+					config.VulnerabilityThreshold = "any"
+				})
+
+				it("fails on findings of unknown severity as well", func() {
+					scanner, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(scanner.Check(findings)).To(MatchError(ContainSubstring("found 2 known vulnerabilities")))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when BP_PIPENV_VULNERABILITY_THRESHOLD is not a severity", func() {
+				it.Before(func() {
+					config.VulnerabilityThreshold = "severe"
+				})
+
+				it("returns an error", func() {
+					_, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+					Expect(err).To(MatchError(`invalid value for $BP_PIPENV_VULNERABILITY_THRESHOLD: "severe" must be one of "any", "low", "medium", "high" or "critical"`))
+				})
+			})
+
+			context("when the installed packages are unknown", func() {
+				it.Before(func() {
+					config.VulnerabilityDB = databaseDir
+				})
+
+				it("returns an error", func() {
+					scanner, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = scanner.Scan(workingDir, nil, logger)
+					Expect(err).To(MatchError("failed to scan for known vulnerabilities: the packages installed into the pipenv layer are unknown"))
+				})
+			})
+
+			context("when the Pipfile.lock is malformed", func() {
+				it.Before(func() {
+					config.VulnerabilityDB = databaseDir
+					config.VulnerabilityScanLockfile = true
+					Expect(os.WriteFile(filepath.Join(workingDir, "Pipfile.lock"), []byte("{"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					scanner, err := pipenv.NewVulnerabilityScanner(platformDir, config)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = scanner.Scan(workingDir, packages, logger)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse Pipfile.lock")))
+				})
+			})
+		})
+	})
+}